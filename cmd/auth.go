@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +27,7 @@ var authCmd = &cobra.Command{
 
 var authSetCmd = &cobra.Command{
 	Use:   "set",
-	Short: "Prompt for auth tokens and persist to config.json (replaces file)",
+	Short: "Prompt for auth tokens and save them to config.json",
 	Run: func(cmd *cobra.Command, args []string) {
 		reader := bufio.NewReader(os.Stdin)
 
@@ -34,39 +39,153 @@ var authSetCmd = &cobra.Command{
 		ynabToken, _ := reader.ReadString('\n')
 		ynabToken = strings.TrimSpace(ynabToken)
 
-		fmt.Print("Enter your YNAB budget ID: ")
-		budgetID, _ := reader.ReadString('\n')
-		budgetID = strings.TrimSpace(budgetID)
+		budgetID := pickYNABBudget(cmd.Context(), reader, ynabToken)
+		if budgetID == "" {
+			errorf("No budget selected; aborting\n")
+			os.Exit(1)
+		}
 
 		// Ensure config directory exists
 		configDir := getConfigDir()
 		if err := os.MkdirAll(configDir, 0700); err != nil {
-			fmt.Printf("Error creating config directory: %v\n", err)
+			errorf("Error creating config directory: %v\n", err)
 			os.Exit(1)
 		}
 
-		cfg := map[string]interface{}{
-			"questrade_refresh_token": questradeRefreshToken,
-			"ynab_access_token":       ynabToken,
-			"ynab_budget_id":          budgetID,
+		unlock, err := lockConfig(configDir)
+		if err != nil {
+			errorf("Error locking config: %v\n", err)
+			os.Exit(1)
 		}
+		defer unlock()
 
-		b, err := json.MarshalIndent(cfg, "", "  ")
+		cfg, err := readConfigJSON(configDir)
 		if err != nil {
-			fmt.Printf("Error encoding config JSON: %v\n", err)
-			os.Exit(1)
+			errorf("Error reading config.json: %v\n", err)
+			os.Exit(exitConfigError)
 		}
+		setAuthConfig(cfg, questradeRefreshToken, ynabToken, budgetID)
 
 		jsonPath := filepath.Join(configDir, "config.json")
-		if err := os.WriteFile(jsonPath, b, 0600); err != nil {
-			fmt.Printf("Error writing config.json: %v\n", err)
+		if err := writeConfigJSON(configDir, cfg); err != nil {
+			errorf("Error writing config.json: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Saved auth values to %s (file replaced)\n", jsonPath)
+		fmt.Printf("Saved auth values to %s\n", jsonPath)
 	},
 }
 
+// questradeSessionKeys are the config.json keys derived from a Questrade refresh token
+var questradeSessionKeys = []string{
+	"questrade_access_token",
+	"questrade_api_server",
+	"questrade_access_token_expires_at",
+	"questrade_expires_in",
+	"questrade_last_refresh_at",
+}
+
+// setAuthConfig stores the values entered in 'auth set' in the config.json map m,
+// leaving other settings such as "sync" and "contributions" in place. A new refresh
+// token drops the session of the old one, which is kept under
+// questrade_previous_refresh_token in case the new one was mistyped.
+func setAuthConfig(m map[string]interface{}, questradeRefreshToken, ynabToken, budgetID string) {
+	if prev, _ := m["questrade_refresh_token"].(string); prev != questradeRefreshToken {
+		if prev != "" {
+			m["questrade_previous_refresh_token"] = prev
+		}
+		for _, k := range questradeSessionKeys {
+			delete(m, k)
+		}
+	}
+	m["questrade_refresh_token"] = questradeRefreshToken
+	m["ynab_access_token"] = ynabToken
+	m["ynab_budget_id"] = budgetID
+}
+
+// pickYNABBudget lets the user choose a budget from their YNAB account, including the
+// "last-used" and "default" aliases, and returns its ID. Without a terminal it asks for a
+// budget ID or alias instead. Either way the choice is checked against the budgets the
+// token can access. It returns "" when no valid budget was chosen.
+func pickYNABBudget(ctx context.Context, reader *bufio.Reader, ynabToken string) string {
+	budgets, defaultBudget, err := ynab.NewClient(ynabToken, "").ListBudgets(ctx)
+	if err != nil {
+		printAPIError("Could not list YNAB budgets", err)
+		return ""
+	}
+	budgetID := chooseYNABBudget(reader, budgets)
+	if budgetID == "" {
+		return ""
+	}
+	// YNAB resolves aliases on every request, so storing one would let the cache, history
+	// and undo follow whichever budget was opened last. Pin the budget it points at now.
+	budget, err := ynab.FindBudget(budgets, defaultBudget, budgetID)
+	if err != nil {
+		errorf("Invalid budget %q: %v\n", budgetID, err)
+		return ""
+	}
+	if ynab.IsBudgetAlias(budgetID) {
+		infof("Using %q, the %s budget (%s)\n", budget.Name, budgetID, budget.ID)
+	}
+	return budget.ID
+}
+
+// chooseYNABBudget asks for a budget ID or alias, offering budgets as a list when a
+// terminal is attached
+func chooseYNABBudget(reader *bufio.Reader, budgets []ynab.BudgetSummary) string {
+	askForID := func() string {
+		fmt.Print("Enter your YNAB budget ID (or 'last-used' / 'default'): ")
+		budgetID, _ := reader.ReadString('\n')
+		return strings.TrimSpace(budgetID)
+	}
+	if !canPrompt() {
+		return askForID()
+	}
+
+	type budgetOption struct {
+		Label string
+		ID    string
+	}
+	var options []budgetOption
+	for _, b := range budgets {
+		label := b.Name
+		if b.CurrencyFormat != nil && b.CurrencyFormat.ISOCode != "" {
+			label = fmt.Sprintf("%s (%s)", b.Name, b.CurrencyFormat.ISOCode)
+		}
+		options = append(options, budgetOption{Label: label, ID: b.ID})
+	}
+	options = append(options,
+		budgetOption{Label: "Last used budget (whichever budget was most recently modified in YNAB)", ID: ynab.LastUsedBudgetID},
+		budgetOption{Label: "Default budget (requires default budget selection in YNAB)", ID: ynab.DefaultBudgetID},
+		budgetOption{Label: "Enter a budget ID manually"},
+	)
+	labels := make([]string, len(options))
+	for i, o := range options {
+		labels[i] = o.Label
+	}
+
+	prompt := promptui.Select{
+		Label: "Select the YNAB budget to sync into",
+		Items: labels,
+		Size:  10,
+		Templates: &promptui.SelectTemplates{
+			Label:    "{{ . }}",
+			Active:   "\u001b[1;44m> {{ . }}\u001b[0m",
+			Inactive: "  {{ . }}",
+			Selected: "\u001b[1;32m✔ {{ . }}\u001b[0m",
+		},
+	}
+	idx, _, err := prompt.Run()
+	if err != nil {
+		errorf("Prompt error: %v\n", err)
+		return ""
+	}
+	if options[idx].ID == "" {
+		return askForID()
+	}
+	return options[idx].ID
+}
+
 var authShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Print the current config.json contents",
@@ -79,7 +198,7 @@ var authShowCmd = &cobra.Command{
 				fmt.Printf("No %s found\n", jsonPath)
 				return
 			}
-			fmt.Printf("Error reading %s: %v\n", jsonPath, err)
+			errorf("Error reading %s: %v\n", jsonPath, err)
 			os.Exit(1)
 		}
 
@@ -95,10 +214,207 @@ var authShowCmd = &cobra.Command{
 	},
 }
 
+// authStatusDocument is the structured form of 'auth status' emitted with --output json|yaml
+type authStatusDocument struct {
+	ConfigPath string              `json:"config_path" yaml:"config_path"`
+	Questrade  questradeAuthStatus `json:"questrade" yaml:"questrade"`
+	YNAB       ynabAuthStatus      `json:"ynab" yaml:"ynab"`
+}
+
+type questradeAuthStatus struct {
+	RefreshTokenSet       bool       `json:"refresh_token_set" yaml:"refresh_token_set"`
+	AccessTokenSet        bool       `json:"access_token_set" yaml:"access_token_set"`
+	APIServer             string     `json:"api_server,omitempty" yaml:"api_server,omitempty"`
+	AccessTokenExpiresAt  *time.Time `json:"access_token_expires_at,omitempty" yaml:"access_token_expires_at,omitempty"`
+	AccessTokenExpired    bool       `json:"access_token_expired" yaml:"access_token_expired"`
+	LastRefreshAt         *time.Time `json:"last_refresh_at,omitempty" yaml:"last_refresh_at,omitempty"`
+	RefreshTokenExpiresAt *time.Time `json:"refresh_token_expires_at,omitempty" yaml:"refresh_token_expires_at,omitempty"`
+	Warnings              []string   `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+type ynabAuthStatus struct {
+	AccessTokenSet bool   `json:"access_token_set" yaml:"access_token_set"`
+	BudgetID       string `json:"budget_id,omitempty" yaml:"budget_id,omitempty"`
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report which credentials are stored and when the Questrade tokens expire",
+	Run: func(cmd *cobra.Command, args []string) {
+		configDir := getConfigDir()
+		jsonPath := filepath.Join(configDir, "config.json")
+
+		var m map[string]interface{}
+		data, err := os.ReadFile(jsonPath)
+		if err != nil && !os.IsNotExist(err) {
+			errorf("Error reading %s: %v\n", jsonPath, err)
+			os.Exit(1)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &m); err != nil {
+				errorf("Error parsing %s: %v\n", jsonPath, err)
+				os.Exit(1)
+			}
+		}
+
+		now := time.Now()
+		state := tokenStateFromConfig(m)
+		doc := authStatusDocument{ConfigPath: jsonPath}
+		doc.Questrade.RefreshTokenSet = state.RefreshToken != ""
+		doc.Questrade.AccessTokenSet = state.AccessToken != ""
+		doc.Questrade.APIServer = state.APIServer
+		if t := state.AccessTokenExpiresAt; !t.IsZero() {
+			doc.Questrade.AccessTokenExpiresAt = &t
+			doc.Questrade.AccessTokenExpired = !now.Before(t)
+		}
+		if t := state.LastRefreshAt; !t.IsZero() {
+			doc.Questrade.LastRefreshAt = &t
+		}
+		if t := state.RefreshTokenExpiresAt(); !t.IsZero() {
+			doc.Questrade.RefreshTokenExpiresAt = &t
+		}
+		doc.Questrade.Warnings = state.warnings(now)
+		if v, _ := m["ynab_access_token"].(string); v != "" {
+			doc.YNAB.AccessTokenSet = true
+		}
+		doc.YNAB.BudgetID, _ = m["ynab_budget_id"].(string)
+
+		if structuredOutput() {
+			if err := printDocument(doc); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		yesNo := func(b bool) string {
+			if b {
+				return "set"
+			}
+			return "missing"
+		}
+		fmt.Printf("Config file: %s\n", doc.ConfigPath)
+		fmt.Println("\nQuestrade:")
+		fmt.Printf("  Refresh token: %s\n", yesNo(doc.Questrade.RefreshTokenSet))
+		fmt.Printf("  Access token:  %s\n", yesNo(doc.Questrade.AccessTokenSet))
+		if doc.Questrade.APIServer != "" {
+			fmt.Printf("  API server:    %s\n", doc.Questrade.APIServer)
+		}
+		if t := doc.Questrade.AccessTokenExpiresAt; t != nil {
+			if doc.Questrade.AccessTokenExpired {
+				fmt.Printf("  Access token expired: %s\n", t.Local().Format(time.RFC1123))
+			} else {
+				fmt.Printf("  Access token expires: %s (in %s)\n", t.Local().Format(time.RFC1123), t.Sub(now).Round(time.Second))
+			}
+		}
+		if t := doc.Questrade.LastRefreshAt; t != nil {
+			fmt.Printf("  Last refresh:  %s\n", t.Local().Format(time.RFC1123))
+		}
+		if t := doc.Questrade.RefreshTokenExpiresAt; t != nil {
+			fmt.Printf("  Refresh token expires: %s (if unused)\n", t.Local().Format(time.RFC1123))
+		}
+		for _, w := range doc.Questrade.Warnings {
+			fmt.Printf("  Warning: %s\n", w)
+		}
+		fmt.Println("\nYNAB:")
+		fmt.Printf("  Access token:  %s\n", yesNo(doc.YNAB.AccessTokenSet))
+		if doc.YNAB.BudgetID != "" {
+			fmt.Printf("  Budget ID:     %s\n", doc.YNAB.BudgetID)
+		} else {
+			fmt.Println("  Budget ID:     missing")
+		}
+	},
+}
+
 func init() {
 	authCmd.AddCommand(authSetCmd)
 	authCmd.AddCommand(authShowCmd)
 	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authSetTokenCmd)
+	authCmd.AddCommand(authKeepaliveCmd)
+	authKeepaliveCmd.Flags().DurationVar(&keepaliveWithin, "within", 72*time.Hour, "Refresh when the refresh token would expire within this window")
+	authKeepaliveCmd.Flags().BoolVar(&keepaliveForce, "force", false, "Refresh regardless of remaining lifetime")
+	authSetTokenCmd.Flags().BoolVar(&setTokenFromStdin, "stdin", false, "Read the refresh token from stdin (e.g. piped from a secret manager)")
+}
+
+var (
+	setTokenFromStdin bool
+	keepaliveWithin   time.Duration
+	keepaliveForce    bool
+)
+
+var authKeepaliveCmd = &cobra.Command{
+	Use:   "keepalive",
+	Short: "Refresh the Questrade token before its 7-day inactivity window lapses",
+	Long: `Refresh the Questrade refresh token when it is close to expiring.
+
+Questrade invalidates refresh tokens that go unused for 7 days. Run this from a
+scheduler (e.g. daily) so an idle setup keeps a valid token; it only contacts
+Questrade when the token expires within --within. Never prompts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		refreshed, validUntil, err := keepQuestradeTokenAlive(cmd.Context(), keepaliveWithin, keepaliveForce)
+		if err != nil {
+			printAPIError("Keepalive failed", err)
+			os.Exit(exitCodeFor(err))
+		}
+		if !refreshed {
+			fmt.Printf("Refresh token valid until %s; no refresh needed\n", validUntil.Local().Format(time.RFC1123))
+			return
+		}
+		fmt.Printf("Refreshed Questrade token; valid until %s if unused\n", validUntil.Local().Format(time.RFC1123))
+	},
+}
+
+var authSetTokenCmd = &cobra.Command{
+	Use:   "set-token [refresh-token]",
+	Short: "Store a new Questrade refresh token without prompting and exchange it for an access token",
+	Long: `Store a new Questrade refresh token and immediately exchange it for an access token.
+
+Intended for unattended setups: pipe the token from a secret manager with --stdin
+rather than passing it as an argument, which would expose it in the process list.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var token string
+		switch {
+		case setTokenFromStdin && len(args) > 0:
+			errorf("Pass the token either as an argument or with --stdin, not both\n")
+			os.Exit(exitConfigError)
+		case setTokenFromStdin:
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				errorf("Error reading token from stdin: %v\n", err)
+				os.Exit(exitError)
+			}
+			token = strings.TrimSpace(line)
+		case len(args) == 1:
+			token = strings.TrimSpace(args[0])
+		}
+		if token == "" {
+			errorf("No refresh token provided\n")
+			os.Exit(exitConfigError)
+		}
+
+		configDir := getConfigDir()
+		unlock, err := lockConfig(configDir)
+		if err != nil {
+			errorf("Error locking config: %v\n", err)
+			os.Exit(exitError)
+		}
+		defer unlock()
+
+		qClient := questrade.NewClient(token)
+		tr, err := qClient.RefreshContext(cmd.Context())
+		if err != nil {
+			printAPIError("Error exchanging the provided token", refreshError(err))
+			os.Exit(exitCodeFor(refreshError(err)))
+		}
+		if err := saveRefreshedTokens(configDir, qClient, tr); err != nil {
+			printAPIError("Error saving the refreshed token", err)
+			os.Exit(exitError)
+		}
+		fmt.Println("Stored new refresh token and refreshed access token in config.json")
+	},
 }
 
 var authLoginCmd = &cobra.Command{
@@ -106,59 +422,53 @@ var authLoginCmd = &cobra.Command{
 	Short: "Ensure Questrade access token is valid; refresh or prompt for new refresh token if needed",
 	Run: func(cmd *cobra.Command, args []string) {
 		configDir := getConfigDir()
-		jsonPath := filepath.Join(configDir, "config.json")
+
+		// Hold the config lock while tokens are validated, refreshed and persisted
+		unlock, err := lockConfig(configDir)
+		if err != nil {
+			errorf("Error locking config: %v\n", err)
+			os.Exit(1)
+		}
+		defer unlock()
 
 		// Load existing config.json if present
-		var m map[string]interface{}
-		if data, err := os.ReadFile(jsonPath); err == nil {
-			_ = json.Unmarshal(data, &m)
-		} else {
+		m, err := readConfigJSON(configDir)
+		if err != nil {
 			m = make(map[string]interface{})
 		}
 
 		// Helper to write config.json
 		writeConfig := func() error {
-			b, err := json.MarshalIndent(m, "", "  ")
-			if err != nil {
-				return err
-			}
-			return os.WriteFile(jsonPath, b, 0600)
+			return writeConfigJSON(configDir, m)
 		}
 
 		// Get tokens from config map
-		refreshToken, _ := m["questrade_refresh_token"].(string)
-		accessToken, _ := m["questrade_access_token"].(string)
-		apiServer, _ := m["questrade_api_server"].(string)
-		expiresIn := 0
-		if v, ok := m["questrade_expires_in"]; ok {
-			switch t := v.(type) {
-			case float64:
-				expiresIn = int(t)
-			case int:
-				expiresIn = t
-			}
-		}
+		state := tokenStateFromConfig(m)
+		refreshToken := state.RefreshToken
 
 		// If no refresh token, prompt user to enter one
 		reader := bufio.NewReader(os.Stdin)
 		if refreshToken == "" {
+			if !canPrompt() {
+				errorf("No Questrade refresh token configured. Provide one with 'questrade-ynab auth set-token --stdin'\n")
+				os.Exit(exitAuthRequired)
+			}
 			fmt.Print("Enter your Questrade manual authorization token (refresh token): ")
 			rt, _ := reader.ReadString('\n')
 			refreshToken = strings.TrimSpace(rt)
 			m["questrade_refresh_token"] = refreshToken
 			if err := writeConfig(); err != nil {
-				fmt.Printf("Warning: failed to write config.json: %v\n", err)
+				errorf("Error writing config.json: %v\n", err)
+				os.Exit(exitError)
 			}
 		}
 
 		qClient := questrade.NewClient(refreshToken)
-		if accessToken != "" && apiServer != "" && expiresIn > 0 {
-			qClient.SetAccessToken(accessToken, apiServer, expiresIn)
-		}
 
-		// Perform a live validation of the cached access token
-		if accessToken != "" && apiServer != "" && expiresIn > 0 {
-			if valid, err := qClient.IsAccessTokenValid(accessToken, apiServer); err == nil && valid {
+		// Perform a live validation of the cached access token unless it is about to expire
+		if state.accessTokenUsable(time.Now()) {
+			qClient.SetAccessToken(state.AccessToken, state.APIServer, state.AccessTokenExpiresAt)
+			if valid, err := qClient.IsAccessTokenValidContext(cmd.Context(), state.AccessToken, state.APIServer); err == nil && valid {
 				fmt.Println("Questrade access token is valid; no action needed")
 				return
 			}
@@ -166,42 +476,88 @@ var authLoginCmd = &cobra.Command{
 		}
 
 		// Try to refresh
-		tr, err := qClient.Refresh()
+		tr, err := qClient.RefreshContext(cmd.Context())
 		if err == nil {
-			// Persist returned tokens
-			if err := updateConfigJSON(configDir, tr.RefreshToken, tr.AccessToken, tr.APIServer, tr.ExpiresIn); err != nil {
-				fmt.Printf("Warning: failed to persist refreshed token: %v\n", err)
-			} else {
-				fmt.Println("Successfully refreshed access token and updated config.json")
+			if err := saveRefreshedTokens(configDir, qClient, tr); err != nil {
+				printAPIError("Error saving the refreshed token", err)
+				os.Exit(exitError)
 			}
+			fmt.Println("Successfully refreshed access token and updated config.json")
 			return
 		}
 
-		// If refresh failed, prompt for a new refresh token
-		fmt.Printf("Refresh failed: %v\n", err)
+		// If the refresh token was rejected, prompt for a new one
+		if !errors.Is(err, questrade.ErrRefreshRejected) {
+			printAPIError("Refresh failed", err)
+			os.Exit(exitError)
+		}
+		errorf("Refresh failed: %v\n", err)
+		if !canPrompt() {
+			fmt.Println("Provide a new refresh token with 'questrade-ynab auth set-token --stdin'")
+			os.Exit(exitAuthRequired)
+		}
 		fmt.Print("Enter a new Questrade refresh token: ")
 		rt, _ := reader.ReadString('\n')
 		rt = strings.TrimSpace(rt)
 		if rt == "" {
-			fmt.Println("No refresh token provided; aborting")
+			errorf("No refresh token provided; aborting\n")
 			os.Exit(1)
 		}
+		if prev, _ := m["questrade_refresh_token"].(string); prev != "" && prev != rt {
+			m["questrade_previous_refresh_token"] = prev
+		}
 		m["questrade_refresh_token"] = rt
 		if err := writeConfig(); err != nil {
-			fmt.Printf("Warning: failed to write config.json: %v\n", err)
+			errorf("Error writing config.json: %v\n", err)
+			os.Exit(exitError)
 		}
 
 		// Try refresh again with new token
 		qClient = questrade.NewClient(rt)
-		tr2, err := qClient.Refresh()
+		tr2, err := qClient.RefreshContext(cmd.Context())
 		if err != nil {
-			fmt.Printf("Failed to refresh with provided token: %v\n", err)
-			os.Exit(1)
+			printAPIError("Failed to refresh with provided token", err)
+			os.Exit(exitCodeFor(refreshError(err)))
 		}
-		if err := updateConfigJSON(configDir, tr2.RefreshToken, tr2.AccessToken, tr2.APIServer, tr2.ExpiresIn); err != nil {
-			fmt.Printf("Warning: failed to persist refreshed token: %v\n", err)
-		} else {
-			fmt.Println("Successfully refreshed access token and updated config.json")
+		if err := saveRefreshedTokens(configDir, qClient, tr2); err != nil {
+			printAPIError("Error saving the refreshed token", err)
+			os.Exit(exitError)
 		}
+		fmt.Println("Successfully refreshed access token and updated config.json")
 	},
 }
+
+// keepQuestradeTokenAlive refreshes the Questrade token when the refresh token expires
+// within window, or always with force, and persists the rotated tokens. It reports
+// whether a refresh happened and until when the refresh token is valid if left unused.
+func keepQuestradeTokenAlive(ctx context.Context, window time.Duration, force bool) (bool, time.Time, error) {
+	configDir := getConfigDir()
+	unlock, err := lockConfig(configDir)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("locking config: %w", err)
+	}
+	defer unlock()
+
+	m, err := readConfigJSON(configDir)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("%w: reading config.json: %v", errConfigInvalid, err)
+	}
+	state := tokenStateFromConfig(m)
+	if state.RefreshToken == "" {
+		return false, time.Time{}, fmt.Errorf("%w: no refresh token configured", ErrReauthRequired)
+	}
+
+	if exp := state.RefreshTokenExpiresAt(); !force && !exp.IsZero() && time.Until(exp) > window {
+		return false, exp, nil
+	}
+
+	qClient := questrade.NewClient(state.RefreshToken)
+	tr, err := qClient.RefreshContext(ctx)
+	if err != nil {
+		return false, time.Time{}, refreshError(err)
+	}
+	if err := saveRefreshedTokens(configDir, qClient, tr); err != nil {
+		return false, time.Time{}, err
+	}
+	return true, time.Now().Add(questrade.RefreshTokenLifetime), nil
+}
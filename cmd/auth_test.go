@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+)
+
+func TestSetAuthConfigKeepsSettings(t *testing.T) {
+	dir := t.TempDir()
+	sync := map[string]interface{}{
+		"min_delta":     5.0,
+		"payee":         "{{if lt .Delta 0.0}}Investment Loss{{else}}Investment Gain{{end}}",
+		"gain_category": "Investment Gains",
+	}
+	contributions := map[string]interface{}{
+		"limits": map[string]interface{}{"2026": map[string]interface{}{"TFSA": 7000.0}},
+	}
+	err := writeConfigJSON(dir, map[string]interface{}{
+		"questrade_refresh_token":           "old-refresh",
+		"questrade_access_token":            "old-access",
+		"questrade_api_server":              "https://api01.iq.questrade.com/",
+		"questrade_access_token_expires_at": "2026-01-05T17:00:00Z",
+		"questrade_last_refresh_at":         "2026-01-05T16:30:00Z",
+		"ynab_access_token":                 "old-ynab",
+		"ynab_budget_id":                    "old-budget",
+		"sync":                              sync,
+		"contributions":                     contributions,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := readConfigJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setAuthConfig(m, "new-refresh", "new-ynab", "new-budget")
+	if err := writeConfigJSON(dir, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readConfigJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"questrade_refresh_token":          "new-refresh",
+		"questrade_previous_refresh_token": "old-refresh",
+		"ynab_access_token":                "new-ynab",
+		"ynab_budget_id":                   "new-budget",
+		"sync":                             sync,
+		"contributions":                    contributions,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config.json after auth set = %v, want %v", got, want)
+	}
+}
+
+func TestSetAuthConfigSameRefreshToken(t *testing.T) {
+	m := map[string]interface{}{
+		"questrade_refresh_token": "refresh",
+		"questrade_access_token":  "access",
+	}
+	setAuthConfig(m, "refresh", "ynab", "budget")
+	if m["questrade_access_token"] != "access" {
+		t.Errorf("access token dropped although the refresh token did not change")
+	}
+	if _, ok := m["questrade_previous_refresh_token"]; ok {
+		t.Errorf("questrade_previous_refresh_token set although the refresh token did not change")
+	}
+}
+
+func TestRefreshError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"rejected refresh token", fmt.Errorf("token refresh failed: %w: %w", questrade.ErrRefreshRejected, &questrade.APIError{StatusCode: 400}), exitAuthRequired},
+		{"server error", fmt.Errorf("token refresh failed: %w", &questrade.APIError{StatusCode: 503}), exitError},
+		{"network failure", errors.New("failed to refresh token: context deadline exceeded"), exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(refreshError(tt.err)); got != tt.want {
+				t.Errorf("exitCodeFor(refreshError(%v)) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
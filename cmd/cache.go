@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func getCacheDir() string {
+	return filepath.Join(getConfigDir(), "cache")
+}
+
+// loadYNABCache returns the local cache for budgetID (empty if none exists yet)
+func loadYNABCache(budgetID string) (*ynab.Cache, string, error) {
+	path := ynab.CachePath(getCacheDir(), budgetID)
+	c, err := ynab.LoadCache(path, budgetID)
+	return c, path, err
+}
+
+// cachedYNABBudget returns the local budget cache with its accounts refreshed by a delta
+// request, so repeated runs only transfer changed accounts. Budget settings (currency
+// format) are fetched once and then served from the cache.
+func cachedYNABBudget(ctx context.Context, yClient *ynab.Client, budgetID string) (*ynab.Cache, error) {
+	cache, path, err := loadYNABCache(budgetID)
+	if err != nil {
+		// A corrupt cache is not fatal; start over with a full fetch
+		infof("Warning: ignoring YNAB cache: %v\n", err)
+		cache = &ynab.Cache{BudgetID: budgetID}
+	}
+	if err := cache.SyncAccounts(ctx, yClient); err != nil {
+		return nil, err
+	}
+	if cache.Settings == nil {
+		if err := cache.SyncSettings(ctx, yClient); err != nil {
+			infof("Warning: failed to fetch YNAB budget settings; amounts use the default currency format: %v\n", err)
+		}
+	}
+	if err := cache.Save(path); err != nil {
+		infof("Warning: failed to save YNAB cache: %v\n", err)
+	}
+	return cache, nil
+}
+
+// budgetCurrencyFormat returns the configured budget's currency format from the local
+// cache without any request, or nil when it has not been cached yet
+func budgetCurrencyFormat() *ynab.CurrencyFormat {
+	budgetID := viper.GetString("ynab_budget_id")
+	if budgetID == "" {
+		if m, err := readConfigJSON(getConfigDir()); err == nil {
+			budgetID, _ = m["ynab_budget_id"].(string)
+		}
+	}
+	if budgetID == "" {
+		return nil
+	}
+	cache, _, err := loadYNABCache(budgetID)
+	if err != nil {
+		return nil
+	}
+	return cache.CurrencyFormat()
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage and browse the local YNAB budget cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Fetch changes to accounts, categories, payees and transactions since the last refresh",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(); err != nil {
+			errorf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		ynabToken := viper.GetString("ynab_access_token")
+		budgetID := viper.GetString("ynab_budget_id")
+		if ynabToken == "" || budgetID == "" {
+			errorf("Missing required YNAB configuration. Please run 'questrade-ynab auth set' first\n")
+			os.Exit(1)
+		}
+
+		cache, path, err := loadYNABCache(budgetID)
+		if err != nil {
+			infof("Warning: ignoring YNAB cache: %v\n", err)
+			cache = &ynab.Cache{BudgetID: budgetID}
+		}
+		yClient := ynab.NewClient(ynabToken, budgetID)
+		if err := cache.Sync(cmd.Context(), yClient); err != nil {
+			printAPIError("Error refreshing YNAB cache", err)
+			os.Exit(1)
+		}
+		if err := cache.Save(path); err != nil {
+			errorf("Error saving YNAB cache: %v\n", err)
+			os.Exit(1)
+		}
+		infof("Cached %d accounts, %d categories, %d payees and %d transactions in %s\n",
+			len(cache.Accounts), len(cache.Categories()), len(cache.Payees), len(cache.Transactions), path)
+	},
+}
+
+var cacheShowAccount string
+
+var cacheShowCmd = &cobra.Command{
+	Use:       "show <accounts|categories|payees|transactions>",
+	Short:     "Browse cached YNAB data without contacting YNAB",
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"accounts", "categories", "payees", "transactions"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(); err != nil {
+			errorf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		budgetID := viper.GetString("ynab_budget_id")
+		cache, _, err := loadYNABCache(budgetID)
+		if err != nil {
+			errorf("Error reading YNAB cache: %v\n", err)
+			os.Exit(1)
+		}
+		if cache.UpdatedAt.IsZero() {
+			fmt.Println("No cached data. Run 'questrade-ynab cache refresh' first")
+			os.Exit(1)
+		}
+		infof("Cached data as of %s\n", cache.UpdatedAt.Local().Format(time.RFC1123))
+
+		var doc interface{}
+		switch args[0] {
+		case "accounts":
+			doc = cache.Accounts
+		case "categories":
+			doc = cache.CategoryGroups
+		case "payees":
+			doc = cache.Payees
+		case "transactions":
+			var txs []ynab.Transaction
+			for _, tx := range cache.Transactions {
+				if cacheShowAccount == "" || tx.AccountID == cacheShowAccount {
+					txs = append(txs, tx)
+				}
+			}
+			doc = txs
+		}
+		if structuredOutput() {
+			if err := printDocument(doc); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		cf := cache.CurrencyFormat()
+		switch v := doc.(type) {
+		case []ynab.Account:
+			for _, acc := range v {
+				fmt.Printf("  %s (%s) %s\n", acc.Name, acc.Type, acc.Balance.Format(cf))
+			}
+		case []ynab.CategoryGroup:
+			for _, g := range v {
+				fmt.Printf("  %s\n", g.Name)
+				for _, c := range g.Categories {
+					fmt.Printf("    %s %s\n", c.Name, c.Balance.Format(cf))
+				}
+			}
+		case []ynab.Payee:
+			for _, p := range v {
+				fmt.Printf("  %s\n", p.Name)
+			}
+		case []ynab.Transaction:
+			for _, tx := range v {
+				fmt.Printf("  %s %-30s %12s %s\n", tx.Date, tx.PayeeName, tx.Amount.Format(cf), strings.TrimSpace(tx.Memo))
+			}
+		}
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the local YNAB cache so the next refresh is a full fetch",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := os.RemoveAll(getCacheDir()); err != nil {
+			errorf("Error deleting cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("YNAB cache cleared")
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheRefreshCmd)
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheShowCmd.Flags().StringVar(&cacheShowAccount, "account", "", "Only show transactions for this YNAB account ID")
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/history"
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// defaultContributionPayee is the payee of the contribution line of a split adjustment
+const defaultContributionPayee = "Contribution"
+
+// categorySettings categorize adjustments on on-budget YNAB accounts. Categories are
+// given by name ("Investment Gains", or "Group: Name" when ambiguous) or ID. They are set
+// globally under "sync" in config.json and per mapping in mappings.json.
+type categorySettings struct {
+	GainCategory string `json:"gain_category,omitempty" yaml:"gain_category,omitempty"`
+	LossCategory string `json:"loss_category,omitempty" yaml:"loss_category,omitempty"`
+	// SplitContributions splits adjustments into a contribution line for deposits and
+	// withdrawals since the last sync and a market-movement line for the rest
+	SplitContributions   *bool  `json:"split_contributions,omitempty" yaml:"split_contributions,omitempty"`
+	ContributionCategory string `json:"contribution_category,omitempty" yaml:"contribution_category,omitempty"`
+	ContributionPayee    string `json:"contribution_payee,omitempty" yaml:"contribution_payee,omitempty"`
+}
+
+func (s categorySettings) isZero() bool {
+	return s.GainCategory == "" && s.LossCategory == "" && s.SplitContributions == nil &&
+		s.ContributionCategory == "" && s.ContributionPayee == ""
+}
+
+// merge returns s with every field set in override replaced
+func (s categorySettings) merge(override categorySettings) categorySettings {
+	if override.GainCategory != "" {
+		s.GainCategory = override.GainCategory
+	}
+	if override.LossCategory != "" {
+		s.LossCategory = override.LossCategory
+	}
+	if override.SplitContributions != nil {
+		s.SplitContributions = override.SplitContributions
+	}
+	if override.ContributionCategory != "" {
+		s.ContributionCategory = override.ContributionCategory
+	}
+	if override.ContributionPayee != "" {
+		s.ContributionPayee = override.ContributionPayee
+	}
+	return s
+}
+
+func (s categorySettings) split() bool {
+	return s.SplitContributions != nil && *s.SplitContributions
+}
+
+// categoryResolver looks up categories in the budget cache, refreshing the cached
+// categories once per run on first use
+type categoryResolver struct {
+	ctx     context.Context
+	client  *ynab.Client
+	cache   *ynab.Cache
+	path    string
+	fetched bool
+}
+
+// resolve returns the category for ref, or nil for an empty ref
+func (r *categoryResolver) resolve(ref string) (*ynab.Category, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	if !r.fetched {
+		r.fetched = true
+		if err := r.cache.SyncCategories(r.ctx, r.client); err != nil {
+			return nil, fmt.Errorf("failed to fetch YNAB categories: %w", err)
+		}
+		if err := r.cache.Save(r.path); err != nil {
+			infof("Warning: failed to save YNAB cache: %v\n", err)
+		}
+	}
+	cat, err := r.cache.FindCategory(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errConfigInvalid, err)
+	}
+	return cat, nil
+}
+
+// contributions sums the deposits and withdrawals in currency recorded by Questrade
+// since the account was last synced. ok is false when there is no previous sync to
+// measure from. cf is the budget's currency format, for the warnings.
+func contributions(ctx context.Context, qClient *questrade.Client, runs []history.Run, accountNumber, ynabAccountID, currency string, cf *ynab.CurrencyFormat) (total ynab.Milliunits, since time.Time, ok bool, err error) {
+	since, ok = history.LastSynced(runs, ynabAccountID)
+	if !ok {
+		return 0, since, false, nil
+	}
+	activities, err := qClient.GetActivitiesContext(ctx, accountNumber, since, time.Now())
+	if err != nil {
+		return 0, since, true, err
+	}
+	for _, a := range activities {
+		if !a.IsContribution() {
+			continue
+		}
+		if currency != "" && a.Currency != currency {
+			// Without an exchange rate the amount cannot be split out exactly
+			infof("Warning: %s %s in account %s left in the market movement line\n", a.Type, ynab.FromUnits(a.NetAmount).Format(ynab.ForCurrency(a.Currency, cf)), accountNumber)
+			continue
+		}
+		total += ynab.FromUnits(a.NetAmount)
+	}
+	return total, since, true, nil
+}
+
+// categorize sets the categories of an adjustment of diff, of which contribution came
+// from deposits and withdrawals. An explicit category_id setting is kept for unsplit
+// adjustments.
+func categorize(r *renderedTx, s categorySettings, resolver *categoryResolver, diff, contribution ynab.Milliunits) error {
+	market := diff - contribution
+	marketRef := ""
+	switch {
+	case market > 0:
+		marketRef = s.GainCategory
+	case market < 0:
+		marketRef = s.LossCategory
+	}
+	marketCat, err := resolver.resolve(marketRef)
+	if err != nil {
+		return err
+	}
+
+	if contribution == 0 {
+		if r.CategoryID == "" && marketCat != nil {
+			r.CategoryID = marketCat.ID
+			r.CategoryName = marketCat.Name
+		}
+		return nil
+	}
+
+	contribCat, err := resolver.resolve(s.ContributionCategory)
+	if err != nil {
+		return err
+	}
+	payee := s.ContributionPayee
+	if payee == "" {
+		payee = defaultContributionPayee
+	}
+	contribLine := ynab.SubTransaction{Amount: contribution, PayeeName: payee, Memo: "Contributions"}
+	marketLine := ynab.SubTransaction{Amount: market, Memo: "Market movement"}
+	r.SplitNames = []string{"", ""}
+	if contribCat != nil {
+		contribLine.CategoryID = contribCat.ID
+		r.SplitNames[0] = contribCat.Name
+	}
+	if marketCat != nil {
+		marketLine.CategoryID = marketCat.ID
+		r.SplitNames[1] = marketCat.Name
+	}
+	// The market line is last so it absorbs any change in the balance since planning
+	r.Subtransactions = []ynab.SubTransaction{contribLine, marketLine}
+	r.CategoryID = ""
+	r.CategoryName = ""
+	return nil
+}
@@ -1,16 +1,41 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/brymastr/questrade-ynab/internal/atomicfile"
 	"github.com/brymastr/questrade-ynab/internal/questrade"
 	"github.com/spf13/viper"
 )
 
+// ErrReauthRequired is returned when the Questrade refresh token is missing or rejected
+// and the user cannot be prompted for a new one (non-interactive mode or no terminal).
+// Feed a new token with 'questrade-ynab auth set-token --stdin' to recover.
+var ErrReauthRequired = errors.New("questrade re-authorization required")
+
+// errConfigInvalid wraps failures to read or parse the configuration
+var errConfigInvalid = errors.New("invalid configuration")
+
+// errTokenNotSaved is returned when Questrade rotated the refresh token but the new one
+// could not be written to config.json. The previous token is spent by then, so the next
+// run needs a new token unless the write problem is fixed first.
+var errTokenNotSaved = errors.New("the rotated Questrade refresh token could not be saved to config.json")
+
+// saveRefreshedTokens persists the tokens a successful refresh of qClient returned
+func saveRefreshedTokens(configDir string, qClient *questrade.Client, tr *questrade.TokenResponse) error {
+	if err := updateConfigJSON(configDir, tr.RefreshToken, tr.AccessToken, tr.APIServer, qClient.GetExpiresAt()); err != nil {
+		return fmt.Errorf("%w: %v", errTokenNotSaved, err)
+	}
+	return nil
+}
+
 func getConfigDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -19,19 +44,72 @@ func getConfigDir() string {
 	return filepath.Join(home, ".questrade-ynab")
 }
 
-// updateConfigJSON updates the config.json file with new token values from the client
-func updateConfigJSON(configDir string, refreshToken, accessToken, apiServer string, expiresIn int) error {
-	jsonPath := filepath.Join(configDir, "config.json")
-	data, err := os.ReadFile(jsonPath)
+// readConfigJSON reads config.json from configDir. A missing file yields an empty map.
+func readConfigJSON(configDir string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]interface{}), nil
+		}
+		return nil, err
+	}
 	var m map[string]interface{}
-	if err == nil {
-		_ = json.Unmarshal(data, &m)
-	} else {
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing config.json: %w", err)
+	}
+	if m == nil {
 		m = make(map[string]interface{})
 	}
+	return m, nil
+}
+
+// readConfigSection decodes the key object of config.json into v. A missing key leaves
+// v unchanged.
+func readConfigSection(configDir, key string, v interface{}) error {
+	m, err := readConfigJSON(configDir)
+	if err != nil {
+		return err
+	}
+	raw, ok := m[key]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error parsing %q in config.json: %w", key, err)
+	}
+	return nil
+}
+
+// writeConfigJSON atomically replaces config.json: the new contents are written to a
+// temporary file in the same directory, synced, and renamed over the original so a crash
+// never leaves a truncated file behind.
+func writeConfigJSON(configDir string, m map[string]interface{}) error {
+	jsonBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+	return atomicfile.Write(filepath.Join(configDir, "config.json"), jsonBytes, 0600)
+}
+
+// updateConfigJSON updates the config.json file with new token values from the client.
+// When the refresh token changes the previous one is kept under questrade_previous_refresh_token.
+// A non-zero expiresAt marks a completed refresh and also records questrade_last_refresh_at.
+// Callers refreshing tokens must hold the config lock (see lockConfig).
+func updateConfigJSON(configDir string, refreshToken, accessToken, apiServer string, expiresAt time.Time) error {
+	m, err := readConfigJSON(configDir)
+	if err != nil {
+		return err
+	}
 
 	// Update tokens and expiration
 	if refreshToken != "" {
+		if prev, _ := m["questrade_refresh_token"].(string); prev != "" && prev != refreshToken {
+			m["questrade_previous_refresh_token"] = prev
+		}
 		m["questrade_refresh_token"] = refreshToken
 	}
 	if accessToken != "" {
@@ -40,16 +118,24 @@ func updateConfigJSON(configDir string, refreshToken, accessToken, apiServer str
 	if apiServer != "" {
 		m["questrade_api_server"] = apiServer
 	}
-	if expiresIn > 0 {
-		m["questrade_expires_in"] = expiresIn
+	if !expiresAt.IsZero() {
+		m["questrade_access_token_expires_at"] = expiresAt.UTC().Format(time.RFC3339)
+		m["questrade_last_refresh_at"] = time.Now().UTC().Format(time.RFC3339)
+		// Relative expiry from older versions is meaningless once reloaded
+		delete(m, "questrade_expires_in")
 	}
 
-	jsonBytes, err := json.MarshalIndent(m, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error encoding JSON: %w", err)
-	}
+	return writeConfigJSON(configDir, m)
+}
 
-	return os.WriteFile(jsonPath, jsonBytes, 0600)
+// refreshError classifies a failed token refresh: a refresh token the token endpoint
+// rejected needs re-authorization, while network, timeout and server errors are
+// returned unchanged so they are not reported as a revoked token
+func refreshError(err error) error {
+	if errors.Is(err, questrade.ErrRefreshRejected) {
+		return fmt.Errorf("%w: %v", ErrReauthRequired, err)
+	}
+	return err
 }
 
 func loadConfig() error {
@@ -62,7 +148,7 @@ func loadConfig() error {
 			var m map[string]interface{}
 			if err := json.Unmarshal(data, &m); err == nil {
 				// Inform the user
-				fmt.Printf("Reading configuration from %s. Delete this file to change values interactively.\n", jsonPath)
+				infof("Reading configuration from %s. Delete this file to change values interactively.\n", jsonPath)
 				if v, ok := m["questrade_refresh_token"].(string); ok {
 					viper.Set("questrade_refresh_token", v)
 				}
@@ -79,9 +165,12 @@ func loadConfig() error {
 				if v, ok := m["questrade_access_token"].(string); ok {
 					viper.Set("questrade_access_token", v)
 				}
-				// Load cached expiration if present
-				if v, ok := m["questrade_expires_in"].(float64); ok {
-					viper.Set("questrade_expires_in", int(v))
+				// Load cached expiration and last refresh time if present
+				if v, ok := m["questrade_access_token_expires_at"].(string); ok {
+					viper.Set("questrade_access_token_expires_at", v)
+				}
+				if v, ok := m["questrade_last_refresh_at"].(string); ok {
+					viper.Set("questrade_last_refresh_at", v)
 				}
 				// account_mapping may be a map; convert to JSON string expected by rest of app
 				if am, ok := m["account_mapping"]; ok {
@@ -115,57 +204,83 @@ func loadConfig() error {
 // It will attempt to validate a cached access token, refresh it if invalid, and prompt the
 // user for a new refresh token if refresh fails. The returned client will have a valid
 // access token and the config.json will be updated with any rotated tokens.
-func ensureValidQuestradeClient() (*questrade.Client, error) {
+// When prompting is not possible it returns an error wrapping ErrReauthRequired instead.
+func ensureValidQuestradeClient(ctx context.Context) (*questrade.Client, error) {
 	// Ensure viper is loaded
 	if err := loadConfig(); err != nil {
 		return nil, err
 	}
 
 	configDir := getConfigDir()
-	refreshToken := viper.GetString("questrade_refresh_token")
-	accessToken := viper.GetString("questrade_access_token")
-	apiServer := viper.GetString("questrade_api_server")
-	expiresIn := viper.GetInt("questrade_expires_in")
+
+	// Hold the config lock for the whole validate-refresh-persist cycle. Questrade refresh
+	// tokens are single use, so two concurrent runs must never spend the same one.
+	unlock, err := lockConfig(configDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Re-read tokens under the lock; another process may have rotated them since loadConfig
+	m, err := readConfigJSON(configDir)
+	if err != nil {
+		return nil, err
+	}
+	state := tokenStateFromConfig(m)
+	if state.RefreshToken == "" {
+		// Legacy YAML configs are only available through viper
+		state.RefreshToken = viper.GetString("questrade_refresh_token")
+	}
+	refreshToken := state.RefreshToken
+	for _, w := range state.warnings(time.Now()) {
+		infof("Warning: %s\n", w)
+	}
 
 	// If there's no refresh token, prompt now
 	if refreshToken == "" {
-		fmt.Print("Enter your Questrade manual authorization token (refresh token): ")
+		if !canPrompt() {
+			return nil, fmt.Errorf("%w: no refresh token configured", ErrReauthRequired)
+		}
+		infof("Enter your Questrade manual authorization token (refresh token): ")
 		var rt string
 		fmt.Scanln(&rt)
 		refreshToken = strings.TrimSpace(rt)
 		if refreshToken == "" {
 			return nil, fmt.Errorf("no refresh token provided")
 		}
-		// Persist refresh token to config.json
-		if err := updateConfigJSON(configDir, refreshToken, "", "", 0); err != nil {
-			// warn but continue
-			fmt.Printf("Warning: failed to persist refresh token to config.json: %v\n", err)
+		// Persist refresh token to config.json before spending it on a refresh
+		if err := updateConfigJSON(configDir, refreshToken, "", "", time.Time{}); err != nil {
+			return nil, fmt.Errorf("saving the refresh token to config.json: %w", err)
 		}
 	}
 
 	qClient := questrade.NewClient(refreshToken)
 
-	// If we have a cached access token, perform live validation
-	if accessToken != "" && apiServer != "" && expiresIn > 0 {
-		qClient.SetAccessToken(accessToken, apiServer, expiresIn)
-		if valid, err := qClient.IsAccessTokenValid(accessToken, apiServer); err == nil && valid {
+	// If the cached access token has not expired, perform live validation. Tokens at or
+	// near expiry are refreshed proactively without spending a request on validation.
+	if state.accessTokenUsable(time.Now()) {
+		qClient.SetAccessToken(state.AccessToken, state.APIServer, state.AccessTokenExpiresAt)
+		if valid, err := qClient.IsAccessTokenValidContext(ctx, state.AccessToken, state.APIServer); err == nil && valid {
 			return qClient, nil
 		}
 	}
 
 	// Try to refresh
-	tr, err := qClient.Refresh()
+	tr, err := qClient.RefreshContext(ctx)
 	if err == nil {
-		// Persist returned tokens
-		if perr := updateConfigJSON(configDir, tr.RefreshToken, tr.AccessToken, tr.APIServer, tr.ExpiresIn); perr != nil {
-			fmt.Printf("Warning: failed to persist refreshed token: %v\n", perr)
+		if err := saveRefreshedTokens(configDir, qClient, tr); err != nil {
+			return nil, err
 		}
 		return qClient, nil
 	}
 
-	// Refresh failed; prompt user for a new refresh token
-	fmt.Printf("Refresh failed: %v\n", err)
-	fmt.Print("Enter a new Questrade refresh token: ")
+	// Refresh failed. Only a rejected refresh token warrants asking for a new one; the
+	// current token is still good after a network or server failure.
+	if !errors.Is(err, questrade.ErrRefreshRejected) || !canPrompt() {
+		return nil, refreshError(err)
+	}
+	infof("Refresh failed: %v\n", err)
+	infof("Enter a new Questrade refresh token: ")
 	var rt string
 	fmt.Scanln(&rt)
 	rt = strings.TrimSpace(rt)
@@ -174,17 +289,17 @@ func ensureValidQuestradeClient() (*questrade.Client, error) {
 	}
 
 	// Persist the new refresh token and try again
-	if err := updateConfigJSON(configDir, rt, "", "", 0); err != nil {
-		fmt.Printf("Warning: failed to persist new refresh token: %v\n", err)
+	if err := updateConfigJSON(configDir, rt, "", "", time.Time{}); err != nil {
+		return nil, fmt.Errorf("saving the refresh token to config.json: %w", err)
 	}
 
 	qClient = questrade.NewClient(rt)
-	tr2, err := qClient.Refresh()
+	tr2, err := qClient.RefreshContext(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh with provided token: %w", err)
+		return nil, fmt.Errorf("failed to refresh with provided token: %w", refreshError(err))
 	}
-	if perr := updateConfigJSON(configDir, tr2.RefreshToken, tr2.AccessToken, tr2.APIServer, tr2.ExpiresIn); perr != nil {
-		fmt.Printf("Warning: failed to persist refreshed token: %v\n", perr)
+	if err := saveRefreshedTokens(configDir, qClient, tr2); err != nil {
+		return nil, err
 	}
 	return qClient, nil
 }
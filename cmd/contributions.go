@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/contribution"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	contributionYear       int
+	contributionLimitFlags map[string]string
+	contributionSetTargets bool
+	contributionAssumeYes  bool
+)
+
+// roomCurrency is the currency contribution room is counted in
+const roomCurrency = "CAD"
+
+// roomTimezone is where contribution years begin and end
+const roomTimezone = "America/Toronto"
+
+// contributionConfig is the "contributions" object of config.json
+type contributionConfig struct {
+	// Limits are the contribution room available in each year, by year and room type,
+	// in dollars
+	Limits map[string]map[string]float64 `json:"limits"`
+	// Categories are the YNAB categories whose targets track the remaining room, by
+	// room type
+	Categories map[string]string `json:"categories"`
+}
+
+// contributionLimits returns the limits for year from config.json with --limit applied
+// on top
+func contributionLimits(cc contributionConfig, year int, flags map[string]string) (map[string]ynab.Milliunits, error) {
+	limits := make(map[string]ynab.Milliunits)
+	set := func(source, roomType string, amount float64) error {
+		t := contribution.NormalizeRoomType(roomType)
+		if t == "" {
+			return fmt.Errorf("%s: unknown account type %q (expected %s)", source, roomType, strings.Join(contribution.RoomTypes, ", "))
+		}
+		if amount < 0 {
+			return fmt.Errorf("%s: limit for %s must not be negative", source, t)
+		}
+		limits[t] = ynab.FromUnits(amount)
+		return nil
+	}
+	for roomType, amount := range cc.Limits[strconv.Itoa(year)] {
+		if err := set("config.json", roomType, amount); err != nil {
+			return nil, err
+		}
+	}
+	for roomType, value := range flags {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("--limit: invalid amount %q for %s", value, roomType)
+		}
+		if err := set("--limit", roomType, amount); err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+// targetChange is a category target --set-targets updates to the remaining room
+type targetChange struct {
+	RoomType   string          `json:"room_type" yaml:"room_type"`
+	CategoryID string          `json:"category_id" yaml:"category_id"`
+	Category   string          `json:"category" yaml:"category"`
+	OldTarget  ynab.Milliunits `json:"old_target_milliunits" yaml:"old_target_milliunits"`
+	NewTarget  ynab.Milliunits `json:"new_target_milliunits" yaml:"new_target_milliunits"`
+	Updated    bool            `json:"updated" yaml:"updated"`
+	Error      string          `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// contributionsDocument is the structured form of 'report contributions'
+type contributionsDocument struct {
+	Year     int                    `json:"year" yaml:"year"`
+	From     time.Time              `json:"from" yaml:"from"`
+	To       time.Time              `json:"to" yaml:"to"`
+	Accounts []contribution.Account `json:"accounts" yaml:"accounts"`
+	Rooms    []contribution.Room    `json:"rooms" yaml:"rooms"`
+	Targets  []targetChange         `json:"targets,omitempty" yaml:"targets,omitempty"`
+}
+
+var reportContributionsCmd = &cobra.Command{
+	Use:   "contributions",
+	Short: "Show contributions to TFSA, RRSP and FHSA accounts and the room left",
+	Long: `Total this year's deposits into registered Questrade accounts from their activities
+and compare them with the contribution room you have, per account type. Spousal RRSPs
+count against your RRSP room. Withdrawals are listed but do not restore room within the
+year. Transfers in and deposits in US dollars are flagged rather than counted: a
+transfer from a non-registered account uses room, a direct transfer from another
+account of the same type does not.
+
+Enter the room available for each year, as shown in CRA My Account, under
+"contributions" in config.json, or pass --limit TFSA=7000 (repeatable):
+
+  "contributions": {
+    "limits": { "2026": { "TFSA": 7000, "RRSP": 18000, "FHSA": 8000 } },
+    "categories": { "TFSA": "TFSA Contributions" }
+  }
+
+With --set-targets the target of each configured YNAB category is set to the room
+remaining. The category must already have a target in YNAB.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(); err != nil {
+			errorf("Error loading config: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		configDir := getConfigDir()
+		var cc contributionConfig
+		if err := readConfigSection(configDir, "contributions", &cc); err != nil {
+			errorf("%v\n", err)
+			os.Exit(exitConfigError)
+		}
+		loc, err := time.LoadLocation(roomTimezone)
+		if err != nil {
+			errorf("Error loading time zone: %v\n", err)
+			os.Exit(exitError)
+		}
+		now := time.Now().In(loc)
+		year := contributionYear
+		if year == 0 {
+			year = now.Year()
+		}
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		to := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+		if from.After(now) {
+			errorf("Invalid --year %d: it has not started yet\n", year)
+			os.Exit(exitConfigError)
+		}
+		if to.After(now) {
+			to = now
+		}
+		limits, err := contributionLimits(cc, year, contributionLimitFlags)
+		if err != nil {
+			errorf("%v\n", err)
+			os.Exit(exitConfigError)
+		}
+
+		qClient, err := ensureValidQuestradeClient(cmd.Context())
+		if err != nil {
+			printAPIError("Error ensuring Questrade auth", err)
+			os.Exit(exitCodeFor(err))
+		}
+		infof("Fetching Questrade accounts...\n")
+		qAccounts, err := qClient.GetAccountsContext(cmd.Context())
+		if err != nil {
+			printAPIError("Error fetching Questrade accounts", err)
+			os.Exit(exitCodeFor(err))
+		}
+
+		doc := contributionsDocument{Year: year, From: from, To: to, Accounts: []contribution.Account{}}
+		for _, acc := range qAccounts {
+			if contribution.RoomType(acc.Type) == "" {
+				continue
+			}
+			infof("Fetching %d activities for %s (%s)...\n", year, acc.Number, acc.Type)
+			activities, err := qClient.GetActivitiesContext(cmd.Context(), acc.Number, from, to)
+			if err != nil {
+				printAPIError(fmt.Sprintf("Error fetching activities for %s", acc.Number), err)
+				os.Exit(exitCodeFor(err))
+			}
+			doc.Accounts = append(doc.Accounts, contribution.Tally(acc.Number, acc.Type, roomCurrency, activities))
+		}
+		sort.Slice(doc.Accounts, func(i, j int) bool { return doc.Accounts[i].Number < doc.Accounts[j].Number })
+		doc.Rooms = contribution.Rooms(doc.Accounts, limits)
+
+		code := exitNoChanges
+		if contributionSetTargets {
+			doc.Targets, code = setRoomTargets(cmd, cc, doc.Rooms)
+		}
+
+		if structuredOutput() {
+			if err := printDocument(doc); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(exitError)
+			}
+			os.Exit(code)
+		}
+		printContributions(doc, ynab.ForCurrency(roomCurrency, budgetCurrencyFormat()))
+		os.Exit(code)
+	},
+}
+
+// setRoomTargets updates the target of each configured category to the remaining room,
+// after approval. It returns the changes and the exit code.
+func setRoomTargets(cmd *cobra.Command, cc contributionConfig, rooms []contribution.Room) ([]targetChange, int) {
+	ynabToken := viper.GetString("ynab_access_token")
+	budgetID := viper.GetString("ynab_budget_id")
+	if ynabToken == "" || budgetID == "" {
+		errorf("Missing required YNAB configuration. Please run 'questrade-ynab auth set' first\n")
+		return nil, exitConfigError
+	}
+	if len(cc.Categories) == 0 {
+		errorf("No categories configured under \"contributions\" in config.json; nothing to set\n")
+		return nil, exitConfigError
+	}
+	yClient := ynab.NewClient(ynabToken, budgetID)
+	cache, path, err := loadYNABCache(budgetID)
+	if err != nil {
+		infof("Warning: ignoring YNAB cache: %v\n", err)
+		cache = &ynab.Cache{BudgetID: budgetID}
+	}
+	resolver := &categoryResolver{ctx: cmd.Context(), client: yClient, cache: cache, path: path}
+	cf := cache.CurrencyFormat()
+
+	var changes []targetChange
+	for _, room := range rooms {
+		ref := ""
+		for t, r := range cc.Categories {
+			if contribution.NormalizeRoomType(t) == room.Type {
+				ref = r
+			}
+		}
+		if ref == "" {
+			continue
+		}
+		if room.Remaining == nil {
+			infof("Skipping %s: no limit configured for it\n", room.Type)
+			continue
+		}
+		cat, err := resolver.resolve(ref)
+		if err != nil {
+			printAPIError(fmt.Sprintf("Error finding the %s category", room.Type), err)
+			return changes, exitCodeFor(err)
+		}
+		if cat.GoalType == "" {
+			infof("Skipping %s: category %q has no target in YNAB; add one first\n", room.Type, cat.Name)
+			continue
+		}
+		target := *room.Remaining
+		if target < 0 {
+			target = 0
+		}
+		if cat.GoalTarget == target {
+			continue
+		}
+		changes = append(changes, targetChange{RoomType: room.Type, CategoryID: cat.ID, Category: cat.Name, OldTarget: cat.GoalTarget, NewTarget: target})
+	}
+	if len(changes) == 0 {
+		infof("Category targets already match the remaining room.\n")
+		return changes, exitNoChanges
+	}
+
+	infof("\nCategory targets to update:\n")
+	for _, c := range changes {
+		infof("  %s (%s): %s → %s\n", c.Category, c.RoomType, c.OldTarget.Format(cf), c.NewTarget.Format(cf))
+	}
+	switch {
+	case contributionAssumeYes:
+	case !canPrompt():
+		infof("Cannot ask for approval; re-run with --yes to update these targets.\n")
+		return changes, exitError
+	default:
+		var response string
+		infof("Update these targets in YNAB? Type 'yes' to approve: ")
+		fmt.Scanln(&response)
+		if strings.ToLower(strings.TrimSpace(response)) != "yes" {
+			infof("Aborted: no targets changed.\n")
+			return changes, exitNoChanges
+		}
+	}
+
+	failed := 0
+	for i := range changes {
+		c := &changes[i]
+		if _, err := yClient.SetCategoryTarget(cmd.Context(), c.CategoryID, c.NewTarget); err != nil {
+			c.Error = err.Error()
+			failed++
+			printAPIError(fmt.Sprintf("Error updating the target of %s", c.Category), err)
+			continue
+		}
+		c.Updated = true
+		infof("✓ Set the target of %s to %s\n", c.Category, c.NewTarget.Format(cf))
+	}
+	switch {
+	case failed == 0:
+		return changes, exitChanges
+	case failed < len(changes):
+		return changes, exitPartialFailure
+	default:
+		return changes, exitError
+	}
+}
+
+// printContributions prints the report with amounts in cf, the budget's currency
+// format adapted to roomCurrency
+func printContributions(doc contributionsDocument, cf *ynab.CurrencyFormat) {
+	fmt.Printf("Contributions %s to %s\n\n", doc.From.Format("2006-01-02"), doc.To.Format("2006-01-02"))
+	if len(doc.Accounts) == 0 {
+		fmt.Println("No TFSA, RRSP or FHSA accounts found.")
+	}
+	for _, a := range doc.Accounts {
+		fmt.Printf("  %s (%s): deposits %s, withdrawals %s\n", a.Number, a.Type, a.Deposits.Format(cf), a.Withdrawals.Format(cf))
+		if a.TransfersIn != 0 {
+			fmt.Printf("    transfers in %s\n", a.TransfersIn.Format(cf))
+		}
+		currencies := make([]string, 0, len(a.Other))
+		for c := range a.Other {
+			currencies = append(currencies, c)
+		}
+		sort.Strings(currencies)
+		for _, c := range currencies {
+			fmt.Printf("    ⚠ %s deposited or transferred in is not converted; add its %s value yourself\n", a.Other[c].Format(ynab.ForCurrency(c, cf)), roomCurrency)
+		}
+	}
+	if len(doc.Rooms) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%-6s %15s %15s %15s\n", "Room", "Contributed", "Limit", "Remaining")
+	for _, r := range doc.Rooms {
+		limit, remaining := "-", "-"
+		if r.Limit != nil {
+			limit = r.Limit.Format(cf)
+			remaining = r.Remaining.Format(cf)
+		}
+		fmt.Printf("%-6s %15s %15s %15s\n", r.Type, r.Contributed.Format(cf), limit, remaining)
+	}
+	for _, r := range doc.Rooms {
+		if r.Over {
+			fmt.Printf("\n⚠ %s over-contributed by %s. CRA charges 1%% per month on excess contributions.\n", r.Type, (-*r.Remaining).Format(cf))
+		}
+		if r.TransfersIn != 0 {
+			fmt.Printf("\n⚠ %s transferred into %s accounts is not counted. Transfers from a non-registered account use room; lower the limit by those.\n", r.TransfersIn.Format(cf), r.Type)
+		}
+		if r.Limit == nil && len(r.Accounts) > 0 {
+			fmt.Printf("\nNo %s limit for %d; set one with --limit %s=AMOUNT or in config.json.\n", r.Type, doc.Year, r.Type)
+		}
+	}
+}
+
+func init() {
+	reportCmd.AddCommand(reportContributionsCmd)
+	reportContributionsCmd.Flags().IntVar(&contributionYear, "year", 0, "Calendar year to report (default: the current year)")
+	reportContributionsCmd.Flags().StringToStringVar(&contributionLimitFlags, "limit", nil, "Contribution room for the year by account type, e.g. TFSA=7000 (repeatable)")
+	reportContributionsCmd.Flags().BoolVar(&contributionSetTargets, "set-targets", false, "Set the targets of the configured YNAB categories to the remaining room")
+	reportContributionsCmd.Flags().BoolVarP(&contributionAssumeYes, "yes", "y", false, "Update category targets without asking for approval")
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonSchedule          string
+	daemonTimezone          string
+	daemonListen            string
+	daemonKeepaliveInterval time.Duration
+	daemonKeepaliveWithin   time.Duration
+	daemonReconcile         bool
+	daemonRunNow            bool
+
+	daemonAfterCloseOnly     bool
+	daemonDateLastTradingDay bool
+	daemonIgnoreValidation   bool
+)
+
+// daemonStatus is the state reported by the health endpoint
+type daemonStatus struct {
+	mu sync.Mutex
+
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	Schedule  string    `json:"schedule"`
+	Timezone  string    `json:"timezone"`
+	NextRunAt time.Time `json:"next_run_at"`
+
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastRunID       string     `json:"last_run_id,omitempty"`
+	LastRunExitCode int        `json:"last_run_exit_code"`
+
+	LastKeepaliveAt    *time.Time `json:"last_keepalive_at,omitempty"`
+	LastKeepaliveError string     `json:"last_keepalive_error,omitempty"`
+	RefreshTokenValid  *time.Time `json:"refresh_token_valid_until,omitempty"`
+}
+
+// healthy reports whether the last sync and keepalive succeeded. Exit codes 0 and 2 are
+// successful syncs with and without changes.
+func (s *daemonStatus) healthy() bool {
+	syncOK := s.LastRunAt == nil || s.LastRunExitCode == exitNoChanges || s.LastRunExitCode == exitChanges
+	return syncOK && s.LastKeepaliveError == ""
+}
+
+func (s *daemonStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := http.StatusOK
+	s.Status = "ok"
+	if !s.healthy() {
+		code = http.StatusServiceUnavailable
+		s.Status = "degraded"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(s)
+}
+
+func (s *daemonStatus) update(fn func(s *daemonStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s)
+}
+
+// serveHealth serves the health endpoint on addr until ctx is cancelled
+func serveHealth(ctx context.Context, addr string, status *daemonStatus) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", status)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Health endpoint stopped: %v", err)
+		}
+	}()
+	log.Printf("Health endpoint listening on http://%s/healthz", ln.Addr())
+	return nil
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run sync on a schedule, keeping the Questrade token alive in between",
+	Long: `Run in the foreground and sync on a cron schedule, replacing external cron jobs.
+
+The schedule is a five-field cron expression (minute hour day-of-month month
+day-of-week) evaluated in --timezone; the default syncs on weekdays at 16:30 Eastern,
+after the TSX and NYSE close. Between runs the Questrade refresh token is refreshed
+before its 7-day inactivity window lapses. Every run is recorded in the history.
+
+The daemon never prompts: changes are applied without approval, except that runs with
+balance issues are refused and changes above max_delta_percent are held back. A
+rejected Questrade token is reported in the log and on the health endpoint until a new
+one is provided with 'questrade-ynab auth set-token --stdin'.
+
+GET /healthz on --listen returns the daemon state as JSON, with status 503 when the
+last sync or keepalive failed. Stop with Ctrl-C or SIGTERM.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loc, err := time.LoadLocation(daemonTimezone)
+		if err != nil {
+			errorf("Invalid --timezone %q: %v\n", daemonTimezone, err)
+			os.Exit(exitConfigError)
+		}
+		sched, err := schedule.Parse(daemonSchedule, loc)
+		if err != nil {
+			errorf("%v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if sched.Next(time.Now()).IsZero() {
+			errorf("Schedule %q never fires\n", daemonSchedule)
+			os.Exit(exitConfigError)
+		}
+		if daemonKeepaliveInterval <= 0 {
+			errorf("--keepalive-interval must be positive\n")
+			os.Exit(exitConfigError)
+		}
+
+		// Nobody is around to answer prompts
+		nonInteractive = true
+		ctx := cmd.Context()
+		status := &daemonStatus{StartedAt: time.Now(), Schedule: sched.String(), Timezone: loc.String()}
+		if daemonListen != "" {
+			if err := serveHealth(ctx, daemonListen, status); err != nil {
+				errorf("Error starting health endpoint: %v\n", err)
+				os.Exit(exitConfigError)
+			}
+		}
+
+		keepalive := func() {
+			refreshed, validUntil, err := keepQuestradeTokenAlive(ctx, daemonKeepaliveWithin, false)
+			status.update(func(s *daemonStatus) {
+				now := time.Now()
+				s.LastKeepaliveAt = &now
+				s.LastKeepaliveError = ""
+				if err != nil {
+					s.LastKeepaliveError = err.Error()
+					return
+				}
+				if !validUntil.IsZero() {
+					s.RefreshTokenValid = &validUntil
+				}
+			})
+			switch {
+			case err != nil:
+				log.Printf("Keepalive failed: %v", err)
+				if hint := remediation(err); hint != "" {
+					log.Printf("  %s", hint)
+				}
+			case refreshed:
+				log.Printf("Refreshed Questrade token; valid until %s if unused", validUntil.Local().Format(time.RFC1123))
+			}
+		}
+		runScheduledSync := func() {
+			log.Printf("Starting scheduled sync")
+			started := time.Now()
+			code := runSync(ctx, syncOptions{
+				AssumeYes:          true,
+				Reconcile:          daemonReconcile,
+				AfterCloseOnly:     daemonAfterCloseOnly,
+				DateLastTradingDay: daemonDateLastTradingDay,
+				IgnoreValidation:   daemonIgnoreValidation,
+			})
+			var runID string
+			if run, err := historyStore().Get("last"); err == nil && !run.StartedAt.Before(started) {
+				runID = run.ID
+			}
+			status.update(func(s *daemonStatus) {
+				now := time.Now()
+				s.LastRunAt = &now
+				s.LastRunID = runID
+				s.LastRunExitCode = code
+			})
+			log.Printf("Sync finished with exit code %d", code)
+		}
+
+		log.Printf("Daemon started; schedule %q in %s", sched, loc)
+		keepalive()
+		if daemonRunNow {
+			runScheduledSync()
+		}
+
+		keepaliveTicker := time.NewTicker(daemonKeepaliveInterval)
+		defer keepaliveTicker.Stop()
+		for {
+			next := sched.Next(time.Now())
+			status.update(func(s *daemonStatus) { s.NextRunAt = next })
+			log.Printf("Next sync at %s", next.Format(time.RFC1123))
+
+			timer := time.NewTimer(time.Until(next))
+		wait:
+			for {
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					log.Printf("Daemon stopped")
+					return
+				case <-keepaliveTicker.C:
+					keepalive()
+				case <-timer.C:
+					runScheduledSync()
+					break wait
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonSchedule, "schedule", "30 16 * * 1-5", "Cron expression for sync runs (minute hour day-of-month month day-of-week)")
+	daemonCmd.Flags().StringVar(&daemonTimezone, "timezone", "America/Toronto", "IANA time zone the schedule is evaluated in")
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "127.0.0.1:8765", "Address for the /healthz endpoint; empty disables it")
+	daemonCmd.Flags().DurationVar(&daemonKeepaliveInterval, "keepalive-interval", 6*time.Hour, "How often to check whether the Questrade token needs refreshing")
+	daemonCmd.Flags().DurationVar(&daemonKeepaliveWithin, "keepalive-within", 72*time.Hour, "Refresh when the refresh token would expire within this window")
+	daemonCmd.Flags().BoolVar(&daemonReconcile, "reconcile", false, "Reconcile mapped YNAB accounts after each sync")
+	daemonCmd.Flags().BoolVar(&daemonAfterCloseOnly, "after-close-only", false, "Skip scheduled syncs that fall while TSX or NYSE is open")
+	daemonCmd.Flags().BoolVar(&daemonDateLastTradingDay, "date-last-trading-day", false, "Date transactions to the last completed trading day")
+	daemonCmd.Flags().BoolVar(&daemonIgnoreValidation, "ignore-validation", false, "Apply changes even when balance checks found issues")
+	daemonCmd.Flags().BoolVar(&daemonRunNow, "run-now", false, "Also sync once at startup")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// remediation suggests how to fix a failed API call, or returns "" if there is nothing
+// more specific to say than the error itself.
+func remediation(err error) string {
+	switch {
+	case errors.Is(err, errTokenNotSaved):
+		return "Questrade has already replaced the previous refresh token. Fix the problem writing config.json now; if the next run fails, generate a new token and run 'questrade-ynab auth set-token --stdin'."
+	case errors.Is(err, ErrReauthRequired):
+		return "Generate a new Questrade token and run 'questrade-ynab auth set-token --stdin' or 'questrade-ynab auth login'."
+	case errors.Is(err, questrade.ErrUnauthorized):
+		return "Questrade rejected the access token. Run 'questrade-ynab auth login' to refresh it."
+	case errors.Is(err, questrade.ErrRateLimited):
+		var apiErr *questrade.APIError
+		if errors.As(err, &apiErr) && !apiErr.Reset.IsZero() {
+			return fmt.Sprintf("Questrade rate limit reached. Try again after %s.", apiErr.Reset.Local().Format(time.Kitchen))
+		}
+		return "Questrade rate limit reached. Try again later."
+	case errors.Is(err, questrade.ErrNotFound):
+		return "Questrade account not found. Run 'questrade-ynab mapping list' to check your mappings."
+	case errors.Is(err, ynab.ErrUnauthorized):
+		return "YNAB rejected the access token. Create a new personal access token in YNAB and run 'questrade-ynab auth set'."
+	case errors.Is(err, ynab.ErrRateLimited):
+		return "YNAB allows 200 requests per hour. Wait a while before retrying."
+	case errors.Is(err, ynab.ErrNotFound):
+		return "YNAB budget or account not found. Check ynab_budget_id with 'questrade-ynab auth show' and your mappings with 'questrade-ynab mapping list'."
+	}
+	return ""
+}
+
+// printAPIError prints what failed followed by a remediation hint when one applies
+func printAPIError(what string, err error) {
+	errorf("%s: %v\n", what, err)
+	if hint := remediation(err); hint != "" {
+		errorf("  %s\n", hint)
+	}
+}
+
+// exitCodeFor picks the sync exit code for a failed API call
+func exitCodeFor(err error) int {
+	switch {
+	case errors.Is(err, ErrReauthRequired), errors.Is(err, questrade.ErrUnauthorized):
+		return exitAuthRequired
+	case errors.Is(err, errConfigInvalid), errors.Is(err, ynab.ErrUnauthorized), errors.Is(err, ynab.ErrNotFound):
+		return exitConfigError
+	}
+	return exitError
+}
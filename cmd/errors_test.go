@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	rejected := fmt.Errorf("token refresh failed: %w: %w", questrade.ErrRefreshRejected, &questrade.APIError{StatusCode: 400})
+
+	tests := []struct {
+		name     string
+		err      error
+		want     int
+		wantHint string
+	}{
+		{"re-authorization required", ErrReauthRequired, exitAuthRequired, "auth set-token"},
+		{"rejected refresh token", refreshError(rejected), exitAuthRequired, "auth set-token"},
+		{"questrade 401", fmt.Errorf("fetch accounts: %w", &questrade.APIError{StatusCode: 401}), exitAuthRequired, "auth login"},
+		{"questrade invalid access token", &questrade.APIError{StatusCode: 400, Code: 1017}, exitAuthRequired, "auth login"},
+		{"invalid configuration", fmt.Errorf("%w: no mappings", errConfigInvalid), exitConfigError, ""},
+		{"ynab 401", &ynab.APIError{StatusCode: 401}, exitConfigError, "auth set'"},
+		{"ynab 404", fmt.Errorf("get account: %w", &ynab.APIError{StatusCode: 404}), exitConfigError, "ynab_budget_id"},
+		{"rotated token not saved", fmt.Errorf("%w: disk full", errTokenNotSaved), exitError, "config.json"},
+		{"questrade rate limited", &questrade.APIError{StatusCode: 429}, exitError, "rate limit"},
+		{"questrade server error", &questrade.APIError{StatusCode: 500}, exitError, ""},
+		{"ynab rate limited", &ynab.APIError{StatusCode: 429}, exitError, "200 requests"},
+		{"transient refresh failure", refreshError(fmt.Errorf("token refresh failed: %w", &questrade.APIError{StatusCode: 503})), exitError, ""},
+		{"network", errors.New("dial tcp: connection refused"), exitError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+			hint := remediation(tt.err)
+			if (tt.wantHint == "") != (hint == "") || !strings.Contains(hint, tt.wantHint) {
+				t.Errorf("remediation(%v) = %q, want it to mention %q", tt.err, hint, tt.wantHint)
+			}
+		})
+	}
+}
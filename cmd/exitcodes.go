@@ -0,0 +1,36 @@
+package cmd
+
+import "os"
+
+// Process exit codes. They follow the "detailed exit code" convention of tools like
+// terraform plan so schedulers can tell a quiet run from one that changed something.
+const (
+	// exitNoChanges means the run completed and there was nothing to do
+	exitNoChanges = 0
+	// exitError is a generic failure (network, API, unexpected errors)
+	exitError = 1
+	// exitChanges means changes were applied, or with --dry-run, are pending
+	exitChanges = 2
+	// exitPartialFailure means some but not all planned changes were applied
+	exitPartialFailure = 3
+	// exitAuthRequired means Questrade re-authorization is needed before syncing
+	exitAuthRequired = 4
+	// exitConfigError means configuration or mappings are missing or invalid
+	exitConfigError = 5
+)
+
+var nonInteractive bool
+
+// stdinIsTerminal reports whether stdin is attached to a terminal
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// canPrompt reports whether the user may be asked questions on stdin
+func canPrompt() bool {
+	return !nonInteractive && stdinIsTerminal()
+}
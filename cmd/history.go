@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/history"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+// historyStore returns the sync run history kept in the config directory
+func historyStore() *history.Store {
+	return history.NewStore(filepath.Join(getConfigDir(), "history.jsonl"))
+}
+
+// historyCurrencyFormat returns the cached currency format of budgetID for displaying
+// recorded amounts, or nil to use the default
+func historyCurrencyFormat(budgetID string) *ynab.CurrencyFormat {
+	cache, _, err := loadYNABCache(budgetID)
+	if err != nil {
+		return nil
+	}
+	return cache.CurrencyFormat()
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse the record of previous sync runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent sync runs, newest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, err := historyStore().List()
+		if err != nil {
+			errorf("Error reading history: %v\n", err)
+			os.Exit(1)
+		}
+		// Newest first, capped at --limit
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+		if historyLimit > 0 && len(runs) > historyLimit {
+			runs = runs[:historyLimit]
+		}
+
+		if structuredOutput() {
+			if runs == nil {
+				runs = []history.Run{}
+			}
+			if err := printDocument(runs); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(runs) == 0 {
+			fmt.Println("No sync runs recorded yet")
+			return
+		}
+		fmt.Printf("%-24s %-25s %-21s %7s %7s %6s\n", "RUN", "STARTED", "OUTCOME", "PLANNED", "CREATED", "ERRORS")
+		for _, run := range runs {
+			outcome := run.Outcome
+			if run.UndoOf != "" {
+				outcome = "undo " + outcome
+			}
+			fmt.Printf("%-24s %-25s %-21s %7d %7d %6d\n", run.ID, run.StartedAt.Local().Format(time.RFC3339), outcome, len(run.Planned), len(run.Created()), len(run.Errors))
+		}
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <run-id|last>",
+	Short: "Show the balances, transactions and errors recorded for a sync run",
+	Long: `Show the balances, transactions and errors recorded for a sync run.
+
+A unique prefix of the run ID is enough; "last" selects the most recent run.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run, err := historyStore().Get(args[0])
+		if err != nil {
+			if errors.Is(err, history.ErrNotFound) {
+				errorf("No sync run matches %q; see 'questrade-ynab history list'\n", args[0])
+			} else {
+				errorf("Error reading history: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		if structuredOutput() {
+			if err := printDocument(run); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		cf := historyCurrencyFormat(run.BudgetID)
+		fmt.Printf("Run:      %s\n", run.ID)
+		fmt.Printf("Started:  %s\n", run.StartedAt.Local().Format(time.RFC1123))
+		fmt.Printf("Finished: %s (%s)\n", run.FinishedAt.Local().Format(time.RFC1123), run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+		fmt.Printf("Outcome:  %s\n", run.Outcome)
+		if run.UndoOf != "" {
+			fmt.Printf("Undo of:  %s\n", run.UndoOf)
+		}
+		if run.DryRun {
+			fmt.Println("Mode:     dry run")
+		}
+		if run.Reconcile {
+			fmt.Println("Mode:     reconcile")
+		}
+		if run.Intraday {
+			fmt.Println("Balances: intraday (markets were open)")
+		}
+
+		if len(run.Accounts) > 0 {
+			fmt.Println("\nBalances:")
+			for _, acc := range run.Accounts {
+				fmt.Printf("  Questrade %s (%s) %s → YNAB %s cleared %s", acc.QuestradeNumber, acc.QuestradeType, acc.QuestradeBalance.Format(cf), acc.YNABName, acc.YNABCleared.Format(cf))
+				if acc.YNABUncleared != 0 {
+					fmt.Printf(", uncleared %s", acc.YNABUncleared.Format(cf))
+				}
+				fmt.Println()
+			}
+		}
+
+		if len(run.Planned) > 0 {
+			fmt.Println("\nPlanned:")
+			for _, p := range run.Planned {
+				fmt.Printf("  %s: %s → %s (delta: %s)\n", p.YNABName, p.OldBalance.Format(cf), p.NewBalance.Format(cf), p.Amount.Format(cf))
+			}
+		}
+
+		if len(run.Transactions) > 0 {
+			fmt.Println("\nTransactions:")
+			for _, tx := range run.Transactions {
+				if tx.Error != "" {
+					fmt.Printf("  ✗ %s %s %s (%s): %s\n", tx.Date, tx.YNABName, tx.Amount.Format(cf), tx.Kind, tx.Error)
+					continue
+				}
+				// Deleted transactions only carry the ID they reverted
+				id := tx.TransactionID
+				if id == "" {
+					id = tx.Reverts
+				}
+				fmt.Printf("  ✓ %s %s %s (%s) id %s\n", tx.Date, tx.YNABName, tx.Amount.Format(cf), tx.Kind, id)
+			}
+		}
+
+		if len(run.Warnings) > 0 {
+			fmt.Println("\nWarnings:")
+			for _, w := range run.Warnings {
+				fmt.Printf("  ⚠ %s\n", w)
+			}
+		}
+
+		if len(run.Errors) > 0 {
+			fmt.Println("\nErrors:")
+			for _, e := range run.Errors {
+				fmt.Printf("  %s\n", e)
+			}
+		}
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyListCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Maximum number of runs to list (0 for all)")
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockConfig is a no-op on platforms without flock; writes are still atomic via rename.
+func lockConfig(configDir string) (func(), error) {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating config directory: %w", err)
+	}
+	return func() {}, nil
+}
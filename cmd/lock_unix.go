@@ -0,0 +1,30 @@
+//go:build unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockConfig takes an exclusive advisory lock on config.lock in configDir, blocking until
+// any other questrade-ynab process releases it. The returned func releases the lock.
+func lockConfig(configDir string) (func(), error) {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating config directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(configDir, "config.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error locking config: %w", err)
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -3,9 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/brymastr/questrade-ynab/internal/ynab"
@@ -24,6 +24,42 @@ type UpdatePreview struct {
 	YNABAccountName string
 }
 
+// mappingListDocument is the structured form of 'mapping list' emitted with --output json|yaml
+type mappingListDocument struct {
+	Currency          string                 `json:"currency,omitempty" yaml:"currency,omitempty"`
+	QuestradeAccounts []questradeAccountView `json:"questrade_accounts" yaml:"questrade_accounts"`
+	YNABAccounts      []ynabAccountView      `json:"ynab_accounts" yaml:"ynab_accounts"`
+	Mappings          []mappingView          `json:"mappings" yaml:"mappings"`
+}
+
+type questradeAccountView struct {
+	Number  string   `json:"number" yaml:"number"`
+	Type    string   `json:"type" yaml:"type"`
+	Status  string   `json:"status" yaml:"status"`
+	Balance *float64 `json:"balance" yaml:"balance"`
+}
+
+type ynabAccountView struct {
+	ID      string          `json:"id" yaml:"id"`
+	Name    string          `json:"name" yaml:"name"`
+	Type    string          `json:"type" yaml:"type"`
+	Balance ynab.Milliunits `json:"balance_milliunits" yaml:"balance_milliunits"`
+	Closed  bool            `json:"closed" yaml:"closed"`
+}
+
+// mappingView describes how a configured mapping resolves against the fetched accounts
+type mappingView struct {
+	QuestradeAccountNumber string `json:"questrade_account_number" yaml:"questrade_account_number"`
+	QuestradeAccountType   string `json:"questrade_account_type,omitempty" yaml:"questrade_account_type,omitempty"`
+	YNABAccountID          string `json:"ynab_account_id" yaml:"ynab_account_id"`
+	YNABAccountName        string `json:"ynab_account_name,omitempty" yaml:"ynab_account_name,omitempty"`
+	Resolved               bool   `json:"resolved" yaml:"resolved"`
+	// Rules are the mapping's own sync rules; unset fields use the global ones
+	Rules syncRules `json:"rules" yaml:"rules"`
+	// Transaction are the mapping's own transaction settings
+	Transaction txSettings `json:"transaction" yaml:"transaction"`
+}
+
 var mappingCmd = &cobra.Command{
 	Use:   "mapping",
 	Short: "Manage account mappings between Questrade and YNAB",
@@ -37,44 +73,32 @@ var mappingListCmd = &cobra.Command{
 	Short: "List Questrade accounts and YNAB accounts for mapping",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := loadConfig(); err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+			errorf("Error loading config: %v\n", err)
 			os.Exit(1)
 		}
 
-		origRefresh := viper.GetString("questrade_refresh_token")
-
-		qClient, err := ensureValidQuestradeClient()
+		qClient, err := ensureValidQuestradeClient(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error ensuring Questrade auth: %v\n", err)
+			printAPIError("Error ensuring Questrade auth", err)
 			os.Exit(1)
 		}
 
 		ynabToken := viper.GetString("ynab_access_token")
 		budgetID := viper.GetString("ynab_budget_id")
 		if ynabToken == "" || budgetID == "" {
-			fmt.Println("Missing required configuration. Please run 'questrade-ynab auth set' or 'questrade-ynab auth login' first")
+			errorf("Missing required configuration. Please run 'questrade-ynab auth set' or 'questrade-ynab auth login' first\n")
 			os.Exit(1)
 		}
 
-		newRefresh := qClient.GetRefreshToken()
-		if newRefresh != "" && newRefresh != origRefresh {
-			viper.Set("questrade_refresh_token", newRefresh)
-			configDir := getConfigDir()
-			if err := viper.WriteConfigAs(filepath.Join(configDir, "config.json")); err != nil {
-				log.Printf("Warning: failed to persist refreshed token: %v", err)
-			}
-		}
-
 		yClient := ynab.NewClient(ynabToken, budgetID)
 
 		// Get Questrade accounts (with balances fetched in parallel)
-		fmt.Println("\nQuestrade Accounts:")
-		fmt.Println("===================")
-		qAccounts, err := qClient.GetAccounts()
+		qAccounts, err := qClient.GetAccountsContext(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error fetching Questrade accounts: %v\n", err)
+			printAPIError("Error fetching Questrade accounts", err)
 			os.Exit(1)
 		}
+		warnMissingBalances(qAccounts)
 
 		// Write Questrade accounts to JSON file for lookup
 		configDir := getConfigDir()
@@ -82,63 +106,108 @@ var mappingListCmd = &cobra.Command{
 		qData, _ := json.MarshalIndent(qAccounts, "", "  ")
 		_ = os.WriteFile(qFile, qData, 0644)
 
-		// Print Questrade accounts (name and balance)
-		for _, acc := range qAccounts {
-			balanceStr := "N/A"
-			if acc.Balances != nil && len(acc.Balances.CombinedBalances) > 0 {
-				totalEquity := acc.Balances.CombinedBalances[0].TotalEquity
-				balanceStr = fmt.Sprintf("$%.2f", totalEquity)
-			}
-			fmt.Printf("  %s %s\n", acc.Type, balanceStr)
-		}
-
 		// Get YNAB accounts
-		fmt.Println("\nYNAB Accounts:")
-		fmt.Println("==============")
-		yAccounts, err := yClient.GetAccounts()
+		budget, err := cachedYNABBudget(cmd.Context(), yClient, budgetID)
 		if err != nil {
-			fmt.Printf("Error fetching YNAB accounts: %v\n", err)
+			printAPIError("Error fetching YNAB accounts", err)
 			os.Exit(1)
 		}
+		yAccounts := budget.Accounts
+		cf := budget.CurrencyFormat()
 
 		// Write YNAB accounts to JSON file for lookup
 		yFile := filepath.Join(configDir, "ynab_accounts.json")
 		yData, _ := json.MarshalIndent(yAccounts, "", "  ")
 		_ = os.WriteFile(yFile, yData, 0644)
 
-		for _, acc := range yAccounts {
-			fmt.Printf("  %s $%.2f\n", acc.Name, float64(acc.Balance)/1000)
+		// Read mapping of Questrade accounts to YNAB accounts
+		accountMapping, err := readMappings(configDir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errorf("Warning: %v\n", err)
+			}
+			accountMapping = make(map[string]mappingEntry)
 		}
 
-		// Print mapping of Questrade accounts to YNAB accounts (by name)
-		mappingPath := filepath.Join(configDir, "mappings.json")
-		var accountMapping map[string]string
-		mappingData, err := os.ReadFile(mappingPath)
-		if err == nil {
-			_ = json.Unmarshal(mappingData, &accountMapping)
-		} else {
-			accountMapping = make(map[string]string)
+		doc := mappingListDocument{
+			QuestradeAccounts: []questradeAccountView{},
+			YNABAccounts:      []ynabAccountView{},
+			Mappings:          []mappingView{},
 		}
-
-		// Build lookup maps for names
 		qNumToName := make(map[string]string)
 		for _, acc := range qAccounts {
+			view := questradeAccountView{Number: acc.Number, Type: acc.Type, Status: acc.Status}
+			if acc.Balances != nil && len(acc.Balances.CombinedBalances) > 0 {
+				totalEquity := acc.Balances.CombinedBalances[0].TotalEquity
+				view.Balance = &totalEquity
+			}
+			doc.QuestradeAccounts = append(doc.QuestradeAccounts, view)
 			qNumToName[acc.Number] = acc.Type
 		}
+		if cf != nil {
+			doc.Currency = cf.ISOCode
+		}
 		yIDToName := make(map[string]string)
 		for _, acc := range yAccounts {
+			doc.YNABAccounts = append(doc.YNABAccounts, ynabAccountView{
+				ID:      acc.ID,
+				Name:    acc.Name,
+				Type:    acc.Type,
+				Balance: acc.Balance,
+				Closed:  acc.Closed,
+			})
 			yIDToName[acc.ID] = acc.Name
 		}
+		for qID, entry := range accountMapping {
+			qName, qOK := qNumToName[qID]
+			yName, yOK := yIDToName[entry.YNABAccountID]
+			doc.Mappings = append(doc.Mappings, mappingView{
+				QuestradeAccountNumber: qID,
+				QuestradeAccountType:   qName,
+				YNABAccountID:          entry.YNABAccountID,
+				YNABAccountName:        yName,
+				Resolved:               qOK && yOK,
+				Rules:                  entry.syncRules,
+				Transaction:            entry.txSettings,
+			})
+		}
+		sort.Slice(doc.Mappings, func(i, j int) bool {
+			return doc.Mappings[i].QuestradeAccountNumber < doc.Mappings[j].QuestradeAccountNumber
+		})
+
+		if structuredOutput() {
+			if err := printDocument(doc); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
 
+		// Print Questrade accounts (name and balance)
+		fmt.Println("\nQuestrade Accounts:")
+		fmt.Println("===================")
+		for _, acc := range doc.QuestradeAccounts {
+			balanceStr := "N/A"
+			if acc.Balance != nil {
+				balanceStr = ynab.FromUnits(*acc.Balance).Format(cf)
+			}
+			fmt.Printf("  %s %s\n", acc.Type, balanceStr)
+		}
+
+		fmt.Println("\nYNAB Accounts:")
+		fmt.Println("==============")
+		for _, acc := range doc.YNABAccounts {
+			fmt.Printf("  %s %s\n", acc.Name, acc.Balance.Format(cf))
+		}
+
+		// Print mapping of Questrade accounts to YNAB accounts (by name)
 		fmt.Println("\nAccount Mappings:")
 		fmt.Println("=================")
-		if len(accountMapping) == 0 {
+		if len(doc.Mappings) == 0 {
 			fmt.Println("  No account mappings found.")
 		} else {
-			for qID, yID := range accountMapping {
-				qName := qNumToName[qID]
-				yName := yIDToName[yID]
-				fmt.Printf("  %s → %s\n", qName, yName)
+			for _, m := range doc.Mappings {
+				fmt.Printf("  %s → %s%s\n", m.QuestradeAccountType, m.YNABAccountName, describeRules(m.Rules))
 			}
 		}
 
@@ -153,9 +222,9 @@ var mappingSetCmd = &cobra.Command{
 	Short: "Interactive account mapping setup (auth must already be configured)",
 	Run: func(cmd *cobra.Command, args []string) {
 		// Ensure we have a valid Questrade client (will prompt or refresh as needed)
-		qClient, err := ensureValidQuestradeClient()
+		qClient, err := ensureValidQuestradeClient(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error ensuring Questrade auth: %v\n", err)
+			printAPIError("Error ensuring Questrade auth", err)
 			os.Exit(1)
 		}
 
@@ -163,7 +232,7 @@ var mappingSetCmd = &cobra.Command{
 		ynabToken := viper.GetString("ynab_access_token")
 		budgetID := viper.GetString("ynab_budget_id")
 		if ynabToken == "" || budgetID == "" {
-			fmt.Println("Missing required YNAB configuration. Please run 'questrade-ynab auth set' first")
+			errorf("Missing required YNAB configuration. Please run 'questrade-ynab auth set' first\n")
 			os.Exit(1)
 		}
 
@@ -171,28 +240,35 @@ var mappingSetCmd = &cobra.Command{
 
 		// Get accounts
 		fmt.Println("\nFetching accounts for mapping setup...")
-		qAccounts, err := qClient.GetAccounts()
+		qAccounts, err := qClient.GetAccountsContext(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error fetching Questrade accounts: %v\n", err)
+			printAPIError("Error fetching Questrade accounts", err)
 			os.Exit(1)
 		}
-		yAccounts, err := yClient.GetAccounts()
+		budget, err := cachedYNABBudget(cmd.Context(), yClient, budgetID)
 		if err != nil {
-			fmt.Printf("Error fetching YNAB accounts: %v\n", err)
+			printAPIError("Error fetching YNAB accounts", err)
 			os.Exit(1)
 		}
-		accountMapping := make(map[string]string)
+		yAccounts := budget.Accounts
+		cf := budget.CurrencyFormat()
+		// Rules configured for accounts that are mapped again are kept
+		previous, err := readMappings(getConfigDir())
+		if err != nil {
+			previous = nil
+		}
+		accountMapping := make(map[string]mappingEntry)
 		for {
 			// Prepare Questrade account options
 			qOptions := []string{}
 			for _, acc := range qAccounts {
 				balanceStr := "N/A"
 				if acc.Balances != nil && len(acc.Balances.CombinedBalances) > 0 {
-					balanceStr = fmt.Sprintf("$%.2f", acc.Balances.CombinedBalances[0].TotalEquity)
+					balanceStr = ynab.FromUnits(acc.Balances.CombinedBalances[0].TotalEquity).Format(cf)
 				}
 				mapped := ""
-				if ynabID, ok := accountMapping[acc.Number]; ok {
-					mapped = fmt.Sprintf(" [MAPPED to %s]", ynabID)
+				if entry, ok := accountMapping[acc.Number]; ok {
+					mapped = fmt.Sprintf(" [MAPPED to %s]", entry.YNABAccountID)
 				}
 				qOptions = append(qOptions, fmt.Sprintf("Account #%s (%s) - Balance: %s%s", acc.Number, acc.Type, balanceStr, mapped))
 			}
@@ -213,7 +289,7 @@ var mappingSetCmd = &cobra.Command{
 			}
 			idx, _, err := prompt.Run()
 			if err != nil {
-				fmt.Printf("Prompt error: %v\n", err)
+				errorf("Prompt error: %v\n", err)
 				break
 			}
 			if idx == len(qOptions)-1 {
@@ -225,7 +301,7 @@ var mappingSetCmd = &cobra.Command{
 			// Prepare YNAB account options
 			yOptions := []string{}
 			for _, acc := range yAccounts {
-				balanceStr := fmt.Sprintf("$%.2f", float64(acc.Balance)/1000)
+				balanceStr := acc.Balance.Format(cf)
 				yOptions = append(yOptions, fmt.Sprintf("%s (%s) - Balance: %s", acc.Name, acc.Type, balanceStr))
 			}
 			yTemplates := &promptui.SelectTemplates{
@@ -242,33 +318,35 @@ var mappingSetCmd = &cobra.Command{
 			}
 			yIdx, _, err := yPrompt.Run()
 			if err != nil {
-				fmt.Printf("Prompt error: %v\n", err)
+				errorf("Prompt error: %v\n", err)
 				continue
 			}
 			selectedYAccount := yAccounts[yIdx]
-			accountMapping[selectedQAccount.Number] = selectedYAccount.ID
+			entry := previous[selectedQAccount.Number]
+			entry.YNABAccountID = selectedYAccount.ID
+			accountMapping[selectedQAccount.Number] = entry
 			fmt.Printf("✓ Mapped Questrade Account #%s to YNAB Account '%s'\n", selectedQAccount.Number, selectedYAccount.Name)
 		}
 
 		// Convert mapping to JSON and persist only mapping to viper/yaml
 		mappingJSON, err := json.Marshal(accountMapping)
 		if err != nil {
-			fmt.Printf("Error creating account mapping: %v\n", err)
+			errorf("Error creating account mapping: %v\n", err)
 			os.Exit(1)
 		}
 
 		configDir := getConfigDir()
 		if err := os.MkdirAll(configDir, 0700); err != nil {
-			fmt.Printf("Error creating config directory: %v\n", err)
+			errorf("Error creating config directory: %v\n", err)
 			os.Exit(1)
 		}
 
 		viper.SetDefault("account_mapping", string(mappingJSON))
 
-		// Write mapping to flat JSON file called mappings.json
-		mappingPath := filepath.Join(configDir, "mappings.json")
-		if err := os.WriteFile(mappingPath, mappingJSON, 0600); err != nil {
-			fmt.Printf("Error writing mappings.json file: %v\n", err)
+		// Write mapping to JSON file called mappings.json
+		mappingPath := mappingsPath(configDir)
+		if err := writeMappings(configDir, accountMapping); err != nil {
+			errorf("Error writing mappings.json file: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -278,10 +356,10 @@ var mappingSetCmd = &cobra.Command{
 		fmt.Println(strings.Repeat("=", 50))
 		fmt.Printf("Mapping saved to %s\n", mappingPath)
 		fmt.Printf("\nAccount Mappings:\n")
-		for qAcctNum, yAcctID := range accountMapping {
+		for qAcctNum, entry := range accountMapping {
 			var yAcctName string
 			for _, acc := range yAccounts {
-				if acc.ID == yAcctID {
+				if acc.ID == entry.YNABAccountID {
 					yAcctName = acc.Name
 					break
 				}
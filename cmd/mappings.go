@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brymastr/questrade-ynab/internal/atomicfile"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// defaultMaxDeltaPercent is the change, relative to the YNAB balance, above which sync
+// asks for explicit confirmation unless configured otherwise
+const defaultMaxDeltaPercent = 20.0
+
+// syncRules decide which balance changes sync applies on its own. They are set globally
+// under "sync" in config.json and can be overridden per mapping in mappings.json. Unset
+// fields fall back to the global value, then the default.
+type syncRules struct {
+	// MinDelta skips changes smaller than this many currency units
+	MinDelta *float64 `json:"min_delta,omitempty" yaml:"min_delta,omitempty"`
+	// MinDeltaPercent skips changes smaller than this percentage of the YNAB balance
+	MinDeltaPercent *float64 `json:"min_delta_percent,omitempty" yaml:"min_delta_percent,omitempty"`
+	// MaxDeltaPercent holds back changes larger than this percentage of the YNAB balance
+	// until explicitly confirmed; 0 disables the guard
+	MaxDeltaPercent *float64 `json:"max_delta_percent,omitempty" yaml:"max_delta_percent,omitempty"`
+}
+
+func (r syncRules) isZero() bool {
+	return r.MinDelta == nil && r.MinDeltaPercent == nil && r.MaxDeltaPercent == nil
+}
+
+// merge returns r with every field set in override replaced
+func (r syncRules) merge(override syncRules) syncRules {
+	if override.MinDelta != nil {
+		r.MinDelta = override.MinDelta
+	}
+	if override.MinDeltaPercent != nil {
+		r.MinDeltaPercent = override.MinDeltaPercent
+	}
+	if override.MaxDeltaPercent != nil {
+		r.MaxDeltaPercent = override.MaxDeltaPercent
+	}
+	return r
+}
+
+func (r syncRules) validate() error {
+	for name, v := range map[string]*float64{
+		"min_delta":         r.MinDelta,
+		"min_delta_percent": r.MinDeltaPercent,
+		"max_delta_percent": r.MaxDeltaPercent,
+	} {
+		if v != nil && (*v < 0 || math.IsNaN(*v) || math.IsInf(*v, 0)) {
+			return fmt.Errorf("%s must be a non-negative number, got %v", name, *v)
+		}
+	}
+	return nil
+}
+
+// deltaPercent is the change as a percentage of the old balance. A change from zero is
+// infinitely large.
+func deltaPercent(old, diff ynab.Milliunits) float64 {
+	if old == 0 {
+		return math.Inf(1)
+	}
+	return math.Abs(float64(diff)) / math.Abs(float64(old)) * 100
+}
+
+// formatPercent renders a deltaPercent value
+func formatPercent(p float64) string {
+	if math.IsInf(p, 0) {
+		return "from zero"
+	}
+	return fmt.Sprintf("%.1f%%", p)
+}
+
+// belowThreshold returns why a change of diff from old is too small to apply, or ""
+func (r syncRules) belowThreshold(old, diff ynab.Milliunits) string {
+	if r.MinDelta != nil && math.Abs(diff.Units()) < *r.MinDelta {
+		return fmt.Sprintf("change below min_delta %.2f", *r.MinDelta)
+	}
+	if r.MinDeltaPercent != nil && deltaPercent(old, diff) < *r.MinDeltaPercent {
+		return fmt.Sprintf("change of %s below min_delta_percent %g%%", formatPercent(deltaPercent(old, diff)), *r.MinDeltaPercent)
+	}
+	return ""
+}
+
+// exceedsMax returns why a change of diff from old needs explicit confirmation, or ""
+func (r syncRules) exceedsMax(old, diff ynab.Milliunits) string {
+	limit := defaultMaxDeltaPercent
+	if r.MaxDeltaPercent != nil {
+		limit = *r.MaxDeltaPercent
+	}
+	if limit == 0 {
+		return ""
+	}
+	if p := deltaPercent(old, diff); p > limit {
+		return fmt.Sprintf("large change: %s (max_delta_percent %g%%)", formatPercent(p), limit)
+	}
+	return ""
+}
+
+// syncConfig is the "sync" object of config.json: the global sync rules, transaction
+// settings and categories
+type syncConfig struct {
+	syncRules
+	txSettings
+	categorySettings
+}
+
+// mappingEntry is the YNAB account a Questrade account syncs to, with its own sync
+// rules, transaction settings and categories. In mappings.json it is either the YNAB account ID as
+// a string or an object.
+type mappingEntry struct {
+	YNABAccountID string `json:"ynab_account_id" yaml:"ynab_account_id"`
+	syncRules
+	txSettings
+	categorySettings
+}
+
+func (m *mappingEntry) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*m = mappingEntry{}
+		return json.Unmarshal(data, &m.YNABAccountID)
+	}
+	// Decode through a distinct type so this method is not called recursively
+	type plain mappingEntry
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = mappingEntry(p)
+	return nil
+}
+
+// MarshalJSON writes entries without rules as plain strings, so mappings.json stays in
+// the flat format older versions read
+func (m mappingEntry) MarshalJSON() ([]byte, error) {
+	if m.syncRules.isZero() && m.txSettings.isZero() && m.categorySettings.isZero() {
+		return json.Marshal(m.YNABAccountID)
+	}
+	type plain mappingEntry
+	return json.Marshal(plain(m))
+}
+
+func mappingsPath(configDir string) string {
+	return filepath.Join(configDir, "mappings.json")
+}
+
+// readMappings reads mappings.json, keyed by Questrade account number
+func readMappings(configDir string) (map[string]mappingEntry, error) {
+	data, err := os.ReadFile(mappingsPath(configDir))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]mappingEntry
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing mappings.json: %w", err)
+	}
+	for qNum, entry := range m {
+		if entry.YNABAccountID == "" {
+			return nil, fmt.Errorf("error parsing mappings.json: %s has no ynab_account_id", qNum)
+		}
+		if err := entry.syncRules.validate(); err != nil {
+			return nil, fmt.Errorf("error parsing mappings.json: %s: %w", qNum, err)
+		}
+	}
+	if m == nil {
+		m = make(map[string]mappingEntry)
+	}
+	return m, nil
+}
+
+// writeMappings atomically replaces mappings.json
+func writeMappings(configDir string, m map[string]mappingEntry) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding mappings: %w", err)
+	}
+	return atomicfile.Write(mappingsPath(configDir), data, 0600)
+}
+
+// globalSyncConfig reads the "sync" object of config.json
+func globalSyncConfig(configDir string) (syncConfig, error) {
+	var sc syncConfig
+	if err := readConfigSection(configDir, "sync", &sc); err != nil {
+		return sc, err
+	}
+	if err := sc.syncRules.validate(); err != nil {
+		return sc, fmt.Errorf("error parsing \"sync\" in config.json: %w", err)
+	}
+	return sc, nil
+}
+
+// describeRules renders a mapping's own rules for 'mapping list', e.g. " [min_delta 5]"
+func describeRules(r syncRules) string {
+	var parts []string
+	if r.MinDelta != nil {
+		parts = append(parts, fmt.Sprintf("min_delta %g", *r.MinDelta))
+	}
+	if r.MinDeltaPercent != nil {
+		parts = append(parts, fmt.Sprintf("min_delta_percent %g%%", *r.MinDeltaPercent))
+	}
+	if r.MaxDeltaPercent != nil {
+		parts = append(parts, fmt.Sprintf("max_delta_percent %g%%", *r.MaxDeltaPercent))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
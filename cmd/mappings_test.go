@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestBelowThreshold(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules syncRules
+		old   float64
+		diff  float64
+		want  string
+	}{
+		{"no rules", syncRules{}, 1000, 0.01, ""},
+		{"below min_delta", syncRules{MinDelta: ptr(5)}, 1000, 4.99, "below min_delta 5.00"},
+		{"negative below min_delta", syncRules{MinDelta: ptr(5)}, 1000, -4.99, "below min_delta 5.00"},
+		{"at min_delta", syncRules{MinDelta: ptr(5)}, 1000, 5, ""},
+		{"above min_delta", syncRules{MinDelta: ptr(5)}, 1000, -5.01, ""},
+		{"below min_delta_percent", syncRules{MinDeltaPercent: ptr(1)}, 1000, 9.99, "change of 1.0% below min_delta_percent 1%"},
+		{"at min_delta_percent", syncRules{MinDeltaPercent: ptr(1)}, 1000, -10, ""},
+		{"percent of a negative balance", syncRules{MinDeltaPercent: ptr(1)}, -1000, 5, "below min_delta_percent"},
+		{"from zero is never too small", syncRules{MinDeltaPercent: ptr(50)}, 0, 0.01, ""},
+		{"min_delta checked first", syncRules{MinDelta: ptr(100), MinDeltaPercent: ptr(50)}, 1000, 1, "below min_delta 100.00"},
+		{"either threshold skips", syncRules{MinDelta: ptr(1), MinDeltaPercent: ptr(50)}, 1000, 10, "below min_delta_percent 50%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.belowThreshold(ynab.FromUnits(tt.old), ynab.FromUnits(tt.diff))
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("belowThreshold(%v, %v) = %q, want %q", tt.old, tt.diff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExceedsMax(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules syncRules
+		old   float64
+		diff  float64
+		want  string
+	}{
+		{"default limit not reached", syncRules{}, 1000, 200, ""},
+		{"default limit exceeded", syncRules{}, 1000, 200.01, "large change: 20.0% (max_delta_percent 20%)"},
+		{"negative change", syncRules{}, 1000, -500, "large change: 50.0%"},
+		{"negative balance", syncRules{}, -1000, 500, "large change: 50.0%"},
+		{"from zero", syncRules{}, 0, 1, "large change: from zero"},
+		{"custom limit", syncRules{MaxDeltaPercent: ptr(50)}, 1000, 400, ""},
+		{"custom limit exceeded", syncRules{MaxDeltaPercent: ptr(5)}, 1000, 60, "large change: 6.0% (max_delta_percent 5%)"},
+		{"zero disables", syncRules{MaxDeltaPercent: ptr(0)}, 0, 1000000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.exceedsMax(ynab.FromUnits(tt.old), ynab.FromUnits(tt.diff))
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("exceedsMax(%v, %v) = %q, want %q", tt.old, tt.diff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncRulesMerge(t *testing.T) {
+	global := syncRules{MinDelta: ptr(1), MaxDeltaPercent: ptr(30)}
+	got := global.merge(syncRules{MinDelta: ptr(5), MinDeltaPercent: ptr(2)})
+	if *got.MinDelta != 5 || *got.MinDeltaPercent != 2 || *got.MaxDeltaPercent != 30 {
+		t.Errorf("merge() = {%v %v %v}, want {5 2 30}", *got.MinDelta, *got.MinDeltaPercent, *got.MaxDeltaPercent)
+	}
+	if *global.MinDelta != 1 {
+		t.Error("merge() modified its receiver")
+	}
+}
+
+func TestSyncRulesValidate(t *testing.T) {
+	if err := (syncRules{MinDelta: ptr(0), MaxDeltaPercent: ptr(20)}).validate(); err != nil {
+		t.Errorf("validate() = %v, want nil", err)
+	}
+	if err := (syncRules{MinDeltaPercent: ptr(-1)}).validate(); err == nil || !strings.Contains(err.Error(), "min_delta_percent") {
+		t.Errorf("validate() = %v, want a min_delta_percent error", err)
+	}
+}
+
+func TestMappingEntryJSON(t *testing.T) {
+	var m map[string]mappingEntry
+	data := `{"111": "ynab-a", "222": {"ynab_account_id": "ynab-b", "min_delta": 5, "payee": "Market"}}`
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["111"].YNABAccountID != "ynab-a" || !m["111"].syncRules.isZero() {
+		t.Errorf("plain entry = %+v", m["111"])
+	}
+	if b := m["222"]; b.YNABAccountID != "ynab-b" || b.MinDelta == nil || *b.MinDelta != 5 || b.Payee != "Market" {
+		t.Errorf("object entry = %+v", b)
+	}
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries without settings stay in the flat format older versions read
+	if !strings.Contains(string(out), `"111":"ynab-a"`) || !strings.Contains(string(out), `"min_delta":5`) {
+		t.Errorf("Marshal() = %s", out)
+	}
+}
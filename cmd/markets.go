@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+)
+
+// syncMarkets are the exchanges whose hours decide whether balances are intraday
+var syncMarkets = []string{"TSX", "NYSE"}
+
+// marketSession is the state of syncMarkets at one point in time
+type marketSession struct {
+	// Now is Questrade's server time, so the local clock does not matter
+	Now time.Time
+	// Open lists the markets whose regular session is in progress
+	Open []string
+	// LastTradingDay is the most recent day any of the markets completed a session
+	LastTradingDay time.Time
+}
+
+// Intraday reports whether balances taken now include unsettled intraday prices
+func (s *marketSession) Intraday() bool {
+	return len(s.Open) > 0
+}
+
+// fetchMarketSession asks Questrade for the time and market hours
+func fetchMarketSession(ctx context.Context, qClient *questrade.Client) (*marketSession, error) {
+	now, err := qClient.GetTimeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markets, err := qClient.GetMarketsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session := &marketSession{Now: now}
+	for _, name := range syncMarkets {
+		m := questrade.FindMarket(markets, name)
+		if m == nil {
+			return nil, fmt.Errorf("market %s not listed by Questrade", name)
+		}
+		if m.IsOpen(now) {
+			session.Open = append(session.Open, m.Name)
+		}
+		if day := m.LastTradingDay(now); day.After(session.LastTradingDay) {
+			session.LastTradingDay = day
+		}
+	}
+	return session, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported values for the global --output flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormat string
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q (expected table, json or yaml)", outputFormat)
+}
+
+// structuredOutput reports whether commands should emit a machine-readable document
+// instead of human text.
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// infof prints progress and informational text. When a structured document is being
+// written to stdout it goes to stderr instead so the document stays parseable.
+func infof(format string, a ...interface{}) {
+	if structuredOutput() {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// errorf prints an error or warning to stderr, so it neither corrupts a structured
+// document on stdout nor hides among regular output.
+func errorf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// printDocument writes v to stdout in the selected structured format
+func printDocument(v interface{}) error {
+	switch outputFormat {
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("error encoding YAML: %w", err)
+		}
+		return enc.Close()
+	default:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("error encoding JSON: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/snapshot"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportBy     string
+	reportFormat string
+	reportPeriod string
+	reportSince  string
+)
+
+// Supported values for report --format
+const (
+	reportFormatTable = "table"
+	reportFormatCSV   = "csv"
+	reportFormatChart = "chart"
+)
+
+// chartWidth is the length of the longest bar in an ASCII chart
+const chartWidth = 50
+
+// reportSinceTime parses --since as a local date, or returns the zero time if unset
+func reportSinceTime() (time.Time, error) {
+	if reportSince == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", reportSince, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q (expected YYYY-MM-DD)", reportSince)
+	}
+	return t, nil
+}
+
+func formatReportAmount(v float64, cf *ynab.CurrencyFormat) string {
+	return ynab.FromUnits(v).Format(cf)
+}
+
+// columnFormats returns the currency format of each column named in names. Columns are
+// in series.Currency, except when grouping by currency, where each column is in the
+// currency it is named after.
+func columnFormats(series *snapshot.Series, names []string) []*ynab.CurrencyFormat {
+	base := budgetCurrencyFormat()
+	formats := make([]*ynab.CurrencyFormat, len(names))
+	for j, name := range names {
+		currency := series.Currency
+		if series.By == snapshot.ByCurrency {
+			currency = name
+		}
+		formats[j] = ynab.ForCurrency(currency, base)
+	}
+	return formats
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports built from recorded balances and sync history",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var reportNetworthCmd = &cobra.Command{
+	Use:   "networth",
+	Short: "Show Questrade balances over time by account, account type or currency",
+	Long: `Show Questrade balances over time from the snapshots recorded by sync and snapshot.
+
+Each row is the last snapshot in its day, week or month (--period). Snapshots of syncs
+whose balance checks failed unattended are left out, and an account whose balance could
+not be fetched keeps its last known balance. Group columns with
+--by account|type|currency; currency groups are each in their own currency and have no
+total. Render with --format table|csv|chart, or as a document with --output json|yaml.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch reportFormat {
+		case reportFormatTable, reportFormatCSV, reportFormatChart:
+		default:
+			errorf("Invalid --format %q (expected table, csv or chart)\n", reportFormat)
+			os.Exit(exitConfigError)
+		}
+		since, err := reportSinceTime()
+		if err != nil {
+			errorf("%v\n", err)
+			os.Exit(exitConfigError)
+		}
+
+		snaps, err := snapshotStore().List()
+		if err != nil {
+			errorf("Error reading snapshots: %v\n", err)
+			os.Exit(exitError)
+		}
+		var filtered []snapshot.Snapshot
+		for _, s := range snaps {
+			if !s.TakenAt.Before(since) {
+				filtered = append(filtered, s)
+			}
+		}
+		series, err := snapshot.Aggregate(filtered, reportBy, reportPeriod, time.Local)
+		if err != nil {
+			errorf("%v\n", err)
+			os.Exit(exitConfigError)
+		}
+
+		if structuredOutput() {
+			if err := printDocument(series); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(exitError)
+			}
+			return
+		}
+		if len(series.Times) == 0 {
+			fmt.Println("No balance snapshots recorded yet. Run 'questrade-ynab sync' or 'questrade-ynab snapshot' first")
+			return
+		}
+
+		switch reportFormat {
+		case reportFormatCSV:
+			err = writeSeriesCSV(series)
+		case reportFormatChart:
+			printSeriesChart(series)
+		default:
+			printSeriesTable(series)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+	},
+}
+
+// seriesColumns returns the column names and values of series, with a Total column
+// appended when the groups can be added up
+func seriesColumns(series *snapshot.Series) ([]string, [][]*float64) {
+	names := append([]string{}, series.Groups...)
+	values := series.Values
+	if totals := series.Totals(); totals != nil {
+		names = append(names, "Total")
+		values = make([][]*float64, len(series.Values))
+		for i := range series.Values {
+			t := totals[i]
+			values[i] = append(append([]*float64{}, series.Values[i]...), &t)
+		}
+	}
+	return names, values
+}
+
+func printSeriesTable(series *snapshot.Series) {
+	names, values := seriesColumns(series)
+	formats := columnFormats(series, names)
+	if series.Currency != "" {
+		fmt.Printf("Balances in %s\n", series.Currency)
+	}
+	fmt.Printf("%-11s", "DATE")
+	for _, name := range names {
+		fmt.Printf(" %16s", name)
+	}
+	fmt.Println()
+	incomplete := false
+	for i, t := range series.Times {
+		date := t.Format("2006-01-02")
+		if series.Incomplete != nil && series.Incomplete[i] {
+			date += "*"
+			incomplete = true
+		}
+		fmt.Printf("%-11s", date)
+		for j, v := range values[i] {
+			cell := "-"
+			if v != nil {
+				cell = formatReportAmount(*v, formats[j])
+			}
+			fmt.Printf(" %16s", cell)
+		}
+		fmt.Println()
+	}
+	if incomplete {
+		fmt.Println("\n* Some balances were missing and had no earlier value; totals are incomplete.")
+	}
+}
+
+func writeSeriesCSV(series *snapshot.Series) error {
+	names, values := seriesColumns(series)
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(append([]string{"date"}, names...)); err != nil {
+		return err
+	}
+	for i, t := range series.Times {
+		record := []string{t.Format("2006-01-02")}
+		for _, v := range values[i] {
+			cell := ""
+			if v != nil {
+				cell = fmt.Sprintf("%.2f", *v)
+			}
+			record = append(record, cell)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printSeriesChart draws one horizontal bar chart per column, scaled to the column's
+// largest value so each trajectory is visible regardless of account size
+func printSeriesChart(series *snapshot.Series) {
+	names, values := seriesColumns(series)
+	formats := columnFormats(series, names)
+	for j, name := range names {
+		max := 0.0
+		for i := range series.Times {
+			if v := values[i][j]; v != nil && math.Abs(*v) > max {
+				max = math.Abs(*v)
+			}
+		}
+		if j > 0 {
+			fmt.Println()
+		}
+		fmt.Println(name)
+		for i, t := range series.Times {
+			v := values[i][j]
+			if v == nil {
+				fmt.Printf("  %s │\n", t.Format("2006-01-02"))
+				continue
+			}
+			n := 0
+			if max > 0 {
+				n = int(math.Round(math.Abs(*v) / max * chartWidth))
+			}
+			bar := strings.Repeat("█", n) + strings.Repeat(" ", chartWidth-n)
+			fmt.Printf("  %s │%s %s\n", t.Format("2006-01-02"), bar, formatReportAmount(*v, formats[j]))
+		}
+	}
+}
+
+func init() {
+	reportCmd.AddCommand(reportNetworthCmd)
+	reportNetworthCmd.Flags().StringVar(&reportBy, "by", snapshot.ByAccount, "Group balances by account, type or currency")
+	reportNetworthCmd.Flags().StringVar(&reportFormat, "format", reportFormatTable, "Render as table, csv or chart")
+	reportNetworthCmd.Flags().StringVar(&reportPeriod, "period", snapshot.PeriodDay, "One row per day, week or month")
+	reportNetworthCmd.Flags().StringVar(&reportSince, "since", "", "Only include snapshots from this date (YYYY-MM-DD)")
+}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -12,17 +14,36 @@ var rootCmd = &cobra.Command{
 	Short: "Sync Questrade investment accounts with YNAB",
 	Long: `A CLI application that fetches current investment account values from Questrade
 and updates the corresponding accounts in YNAB (You Need A Budget).`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat()
+	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	// Cancel in-flight API requests on Ctrl-C or SIGTERM. Default signal handling is
+	// restored after the first signal so a second Ctrl-C still kills a blocked prompt.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		errorf("%v\n", err)
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt for input; fail instead of waiting on stdin")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table, json or yaml")
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(mappingCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(daemonCmd)
 }
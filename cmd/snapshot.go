@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/snapshot"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+)
+
+// snapshotStore returns the balance snapshots kept in the config directory
+func snapshotStore() *snapshot.Store {
+	return snapshot.NewStore(filepath.Join(getConfigDir(), "snapshots.jsonl"))
+}
+
+// recordSnapshot saves snap for net worth reporting. Failures only warn, since a missing
+// data point should never fail the command that fetched it.
+func recordSnapshot(snap snapshot.Snapshot) {
+	if len(snap.Accounts) == 0 {
+		return
+	}
+	if err := snapshotStore().Append(snap); err != nil {
+		infof("Warning: failed to record balance snapshot: %v\n", err)
+	}
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Record current Questrade balances for net worth reporting",
+	Long: `Fetch current Questrade balances and record them for 'report networth' without syncing
+to YNAB. sync records a snapshot too, so this is only needed for extra data points.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(); err != nil {
+			errorf("Error loading config: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		qClient, err := ensureValidQuestradeClient(cmd.Context())
+		if err != nil {
+			printAPIError("Error ensuring Questrade auth", err)
+			os.Exit(exitCodeFor(err))
+		}
+
+		intraday := false
+		if session, err := fetchMarketSession(cmd.Context(), qClient); err == nil {
+			intraday = session.Intraday()
+		} else {
+			infof("Warning: could not check market hours; snapshot is not tagged intraday: %v\n", err)
+		}
+
+		infof("Fetching Questrade accounts...\n")
+		qAccounts, err := qClient.GetAccountsContext(cmd.Context())
+		if err != nil {
+			printAPIError("Error fetching Questrade accounts", err)
+			os.Exit(exitCodeFor(err))
+		}
+		warnMissingBalances(qAccounts)
+		snap := snapshot.FromAccounts(qAccounts, snapshot.SourceSnapshot, time.Now(), intraday)
+		if len(snap.Accounts) == 0 {
+			fmt.Println("No Questrade balances to record")
+			os.Exit(exitError)
+		}
+		if err := snapshotStore().Append(snap); err != nil {
+			errorf("Error recording snapshot: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		if structuredOutput() {
+			if err := printDocument(snap); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(exitError)
+			}
+			return
+		}
+		fmt.Printf("Recorded balances at %s:\n", snap.TakenAt.Local().Format(time.RFC1123))
+		cf := budgetCurrencyFormat()
+		for _, acc := range snap.Accounts {
+			fmt.Printf("  %s (%s): %s\n", acc.Number, acc.Type, formatReportAmount(acc.TotalEquity, ynab.ForCurrency(acc.Currency, cf)))
+		}
+	},
+}
@@ -1,178 +1,721 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/brymastr/questrade-ynab/internal/history"
 	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/snapshot"
 	"github.com/brymastr/questrade-ynab/internal/ynab"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var dryRun bool
+var (
+	dryRun    bool
+	assumeYes bool
+	reconcile bool
+
+	afterCloseOnly     bool
+	dateLastTradingDay bool
+	allowLargeChanges  bool
+	ignoreValidation   bool
+)
+
+// reconcileRequestsPerAccount is the most YNAB requests ReconcileAccount makes per account
+const reconcileRequestsPerAccount = 4
+
+// adjustRequestsPerTx is the number of YNAB requests SetAccountBalance makes per adjustment
+const adjustRequestsPerTx = 2
+
+// PlannedTx is a balance adjustment sync intends to create in YNAB. OldBalance is the
+// YNAB cleared balance; Uncleared is the total of pending transactions, which the
+// adjustment does not touch.
+type PlannedTx struct {
+	QuestradeName string          `json:"questrade_account" yaml:"questrade_account"`
+	YNABName      string          `json:"ynab_account" yaml:"ynab_account"`
+	YNABAccountID string          `json:"ynab_account_id" yaml:"ynab_account_id"`
+	OldBalance    ynab.Milliunits `json:"old_balance_milliunits" yaml:"old_balance_milliunits"`
+	NewBalance    ynab.Milliunits `json:"new_balance_milliunits" yaml:"new_balance_milliunits"`
+	Amount        ynab.Milliunits `json:"amount_milliunits" yaml:"amount_milliunits"`
+	Uncleared     ynab.Milliunits `json:"uncleared_milliunits" yaml:"uncleared_milliunits"`
+	// LargeChange is set when the change exceeds max_delta_percent and needs confirmation
+	LargeChange string `json:"large_change,omitempty" yaml:"large_change,omitempty"`
+	// Transaction is the payee, memo and flags the adjustment is posted with
+	Transaction renderedTx `json:"transaction" yaml:"transaction"`
+}
+
+// SkippedTx is a balance change sync left alone because it is below the mapping's thresholds
+type SkippedTx struct {
+	QuestradeName string          `json:"questrade_account" yaml:"questrade_account"`
+	YNABName      string          `json:"ynab_account" yaml:"ynab_account"`
+	Amount        ynab.Milliunits `json:"amount_milliunits" yaml:"amount_milliunits"`
+	Reason        string          `json:"reason" yaml:"reason"`
+}
+
+// TxResult is the outcome of creating a single planned transaction
+type TxResult struct {
+	YNABName string          `json:"ynab_account" yaml:"ynab_account"`
+	Amount   ynab.Milliunits `json:"amount_milliunits" yaml:"amount_milliunits"`
+	Created  bool            `json:"created" yaml:"created"`
+	Error    string          `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// reconcileTarget is a mapped YNAB account and the Questrade balance it should reconcile to
+type reconcileTarget struct {
+	YNABName      string
+	YNABAccountID string
+	Balance       ynab.Milliunits
+}
+
+// ReconcileOutcome is the result of reconciling one YNAB account with --reconcile
+type ReconcileOutcome struct {
+	YNABName       string          `json:"ynab_account" yaml:"ynab_account"`
+	Balance        ynab.Milliunits `json:"balance_milliunits" yaml:"balance_milliunits"`
+	ClearedBalance ynab.Milliunits `json:"cleared_balance_milliunits" yaml:"cleared_balance_milliunits"`
+	Adjustment     ynab.Milliunits `json:"adjustment_milliunits" yaml:"adjustment_milliunits"`
+	Reconciled     int             `json:"transactions_reconciled" yaml:"transactions_reconciled"`
+	Error          string          `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// syncDocument is the structured form of a sync run emitted with --output json|yaml.
+// YNABRequestsRemaining is the hourly YNAB request budget left before applying, -1 if unknown.
+type syncDocument struct {
+	RunID    string `json:"run_id" yaml:"run_id"`
+	DryRun   bool   `json:"dry_run" yaml:"dry_run"`
+	Currency string `json:"currency,omitempty" yaml:"currency,omitempty"`
+	// Intraday is set when balances were fetched while TSX or NYSE was open
+	Intraday              bool        `json:"intraday" yaml:"intraday"`
+	TransactionDate       string      `json:"transaction_date" yaml:"transaction_date"`
+	YNABRequestsRemaining int         `json:"ynab_requests_remaining" yaml:"ynab_requests_remaining"`
+	Outcome               string      `json:"outcome" yaml:"outcome"`
+	Planned               []PlannedTx `json:"planned" yaml:"planned"`
+	Skipped               []SkippedTx `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	// Issues are problems found checking the Questrade data; they block unattended applies
+	Issues   []balanceIssue `json:"issues,omitempty" yaml:"issues,omitempty"`
+	Approved bool           `json:"approved" yaml:"approved"`
+	Results  []TxResult     `json:"results,omitempty" yaml:"results,omitempty"`
+	// Reconciled is only populated with --reconcile
+	Reconciled []ReconcileOutcome `json:"reconciled,omitempty" yaml:"reconciled,omitempty"`
+	// Errors are what failed, including errors that stopped the run before planning
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync Questrade account balances to YNAB",
-	Long:  "Fetch investment account balances from Questrade and update the corresponding accounts in YNAB by creating transactions.",
+	Long: `Fetch investment account balances from Questrade and update the corresponding accounts in YNAB by creating transactions.
+
+With --reconcile, each mapped YNAB account is then reconciled against its Questrade balance:
+if the cleared balance still differs a "Reconciliation Balance Adjustment" is posted, and all
+cleared transactions are marked reconciled.
+
+Changes below the min_delta / min_delta_percent thresholds are skipped. Changes above
+max_delta_percent (default 20% of the YNAB balance) need explicit confirmation: they are
+held back under --yes unless --allow-large-changes is given.
+
+Questrade data is checked before it is synced: missing accounts and balances, zero
+balances, balances that are not real-time, currencies that differ from the budget's and
+accounts that disappeared since the last run are listed in the preview. With --yes any
+such issue refuses the sync unless --ignore-validation is given.
+
+Only --yes approves changes. With --non-interactive, or when stdin is not a terminal,
+sync without --yes refuses to apply them.
+
+The payee, memo, cleared status, approval, flag color and category of adjustments are
+text/template strings set under "sync" in config.json or per mapping; see the README.
+On on-budget accounts adjustments can be given gain and loss categories and split into
+contributions and market movement.
+
+Exit codes:
+  0  no changes needed
+  1  error
+  2  changes applied (or pending, with --dry-run)
+  3  partial failure: some transactions could not be created or were held back
+  4  Questrade authentication required
+  5  configuration error`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := loadConfig(); err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
-			os.Exit(1)
-		}
+		os.Exit(runSync(cmd.Context(), syncOptions{
+			DryRun:             dryRun,
+			AssumeYes:          assumeYes,
+			Reconcile:          reconcile,
+			AfterCloseOnly:     afterCloseOnly,
+			DateLastTradingDay: dateLastTradingDay,
+			AllowLargeChanges:  allowLargeChanges,
+			IgnoreValidation:   ignoreValidation,
+		}))
+	},
+}
 
-		// Ensure a valid Questrade client (will refresh or prompt as needed)
-		qClient, err := ensureValidQuestradeClient()
-		if err != nil {
-			fmt.Printf("Error ensuring Questrade auth: %v\n", err)
-			os.Exit(1)
-		}
+// syncOptions controls a single sync run
+type syncOptions struct {
+	DryRun bool
+	// AssumeYes applies planned changes without asking for approval
+	AssumeYes bool
+	Reconcile bool
+	// AfterCloseOnly defers the sync while TSX or NYSE is open
+	AfterCloseOnly bool
+	// DateLastTradingDay dates transactions to the last completed trading day
+	DateLastTradingDay bool
+	// AllowLargeChanges applies changes above max_delta_percent without confirmation
+	AllowLargeChanges bool
+	// IgnoreValidation applies changes under AssumeYes despite balance issues
+	IgnoreValidation bool
+}
 
-		// Ensure YNAB values are present
-		ynabToken := viper.GetString("ynab_access_token")
-		budgetID := viper.GetString("ynab_budget_id")
-		if ynabToken == "" || budgetID == "" {
-			fmt.Println("Missing required configuration. Please run 'questrade-ynab auth set' or 'questrade-ynab auth login' first")
-			os.Exit(1)
+// runSync performs one sync run and returns its process exit code. It is shared by the
+// sync command and the daemon scheduler. Every run is recorded in the history, including
+// runs that fail before planning.
+func runSync(ctx context.Context, opts syncOptions) int {
+	startedAt := time.Now()
+	run := history.Run{
+		ID:        history.NewRunID(startedAt),
+		StartedAt: startedAt,
+		DryRun:    opts.DryRun,
+		Reconcile: opts.Reconcile,
+	}
+	doc := syncDocument{RunID: run.ID, DryRun: opts.DryRun, YNABRequestsRemaining: -1}
+	// fetched is the snapshot of the Questrade balances, recorded when the run finishes so
+	// it is only left unvalidated when the balance checks found issues nobody has seen
+	var fetched *snapshot.Snapshot
+	validated := false
+	// finish records the run in the history, emits the structured document and returns code
+	finish := func(outcome string, code int) int {
+		if fetched != nil {
+			fetched.Unvalidated = !validated
+			recordSnapshot(*fetched)
 		}
-
-		// Read mapping from ~/.questrade-ynab/mappings.json
-		configDir := getConfigDir()
-		mappingPath := filepath.Join(configDir, "mappings.json")
-		mappingData, err := os.ReadFile(mappingPath)
-		if err != nil {
-			fmt.Printf("Error reading mappings.json: %v\n", err)
-			os.Exit(1)
+		run.Outcome = outcome
+		run.FinishedAt = time.Now()
+		if err := historyStore().Append(run); err != nil {
+			errorf("Warning: failed to record sync history: %v\n", err)
 		}
-		var accountMapping map[string]string
-		if err := json.Unmarshal(mappingData, &accountMapping); err != nil {
-			fmt.Printf("Error parsing mappings.json: %v\n", err)
-			os.Exit(1)
+		if structuredOutput() {
+			doc.Outcome = outcome
+			doc.Errors = run.Errors
+			if err := printDocument(doc); err != nil {
+				errorf("%v\n", err)
+				return exitError
+			}
 		}
+		return code
+	}
+	// fail finishes a run stopped by err; the error has already been printed
+	fail := func(code int, err error) int {
+		run.Errors = append(run.Errors, err.Error())
+		return finish(history.OutcomeFailed, code)
+	}
 
-		yClient := ynab.NewClient(ynabToken, budgetID)
+	if err := loadConfig(); err != nil {
+		errorf("Error loading config: %v\n", err)
+		return fail(exitConfigError, fmt.Errorf("loading config: %w", err))
+	}
 
-		// Get Questrade accounts
-		fmt.Println("Fetching Questrade accounts...")
-		qAccounts, err := qClient.GetAccounts()
-		if err != nil {
-			fmt.Printf("Error fetching Questrade accounts: %v\n", err)
-			os.Exit(1)
-		}
-		if len(qAccounts) == 0 {
-			fmt.Println("No Questrade accounts found")
-			os.Exit(1)
+	// Ensure a valid Questrade client (will refresh or prompt as needed)
+	qClient, err := ensureValidQuestradeClient(ctx)
+	if err != nil {
+		printAPIError("Error ensuring Questrade auth", err)
+		return fail(exitCodeFor(err), fmt.Errorf("questrade auth: %w", err))
+	}
+
+	// Ensure YNAB values are present
+	ynabToken := viper.GetString("ynab_access_token")
+	budgetID := viper.GetString("ynab_budget_id")
+	run.BudgetID = budgetID
+	if ynabToken == "" || budgetID == "" {
+		errorf("Missing required configuration. Please run 'questrade-ynab auth set' or 'questrade-ynab auth login' first\n")
+		return fail(exitConfigError, errors.New("missing YNAB access token or budget ID"))
+	}
+
+	// Read mapping from ~/.questrade-ynab/mappings.json
+	configDir := getConfigDir()
+	accountMapping, err := readMappings(configDir)
+	if err != nil {
+		errorf("Error reading mappings.json: %v\n", err)
+		return fail(exitConfigError, fmt.Errorf("reading mappings.json: %w", err))
+	}
+	global, err := globalSyncConfig(configDir)
+	if err != nil {
+		errorf("Error reading config.json: %v\n", err)
+		return fail(exitConfigError, fmt.Errorf("reading config.json: %w", err))
+	}
+
+	yClient := ynab.NewClient(ynabToken, budgetID)
+
+	// Balances taken while markets are open move with intraday prices
+	txDate := time.Now().Format("2006-01-02")
+	session, err := fetchMarketSession(ctx, qClient)
+	switch {
+	case err != nil && opts.AfterCloseOnly:
+		printAPIError("Error checking market hours (required by --after-close-only)", err)
+		return fail(exitCodeFor(err), fmt.Errorf("checking market hours: %w", err))
+	case err != nil:
+		infof("Warning: could not check market hours; balances are not tagged intraday: %v\n", err)
+		session = nil
+	case session.Intraday() && opts.AfterCloseOnly:
+		infof("%s open; deferring sync until after the close (--after-close-only).\n", strings.Join(session.Open, " and "))
+		run.Intraday = true
+		return finish(history.OutcomeDeferred, exitNoChanges)
+	case session.Intraday():
+		infof("Note: %s open; balances include intraday prices.\n", strings.Join(session.Open, " and "))
+	}
+	if opts.DateLastTradingDay {
+		if session != nil {
+			txDate = session.LastTradingDay.Format("2006-01-02")
+			infof("Dating transactions to the last trading day, %s.\n", txDate)
+		} else {
+			infof("Warning: last trading day unknown; dating transactions today.\n")
 		}
+	}
+	intraday := session != nil && session.Intraday()
+	run.Intraday = intraday
+	doc.Intraday = intraday
+	doc.TransactionDate = txDate
+
+	// Get Questrade accounts
+	infof("Fetching Questrade accounts...\n")
+	qAccounts, err := qClient.GetAccountsContext(ctx)
+	if err != nil {
+		printAPIError("Error fetching Questrade accounts", err)
+		return fail(exitCodeFor(err), fmt.Errorf("fetching Questrade accounts: %w", err))
+	}
+	if len(qAccounts) == 0 {
+		errorf("No Questrade accounts found\n")
+		return fail(exitError, errors.New("no Questrade accounts found"))
+	}
+	prevSnapshot := lastSnapshot()
+	snap := snapshot.FromAccounts(qAccounts, snapshot.SourceSync, time.Now(), intraday)
+	fetched = &snap
+
+	// Get YNAB accounts
+	infof("Fetching YNAB accounts...\n")
+	budget, err := cachedYNABBudget(ctx, yClient, budgetID)
+	if err != nil {
+		printAPIError("Error fetching YNAB accounts", err)
+		return fail(exitCodeFor(err), fmt.Errorf("fetching YNAB accounts: %w", err))
+	}
+	yAccounts := budget.Accounts
+	cf := budget.CurrencyFormat()
+	yAccountsMap := make(map[string]*ynab.Account)
+	for i := range yAccounts {
+		yAccountsMap[yAccounts[i].ID] = &yAccounts[i]
+	}
 
-		// Get YNAB accounts
-		fmt.Println("Fetching YNAB accounts...")
-		yAccounts, err := yClient.GetAccounts()
+	if cf != nil {
+		run.Currency = cf.ISOCode
+	}
+	doc.Currency = run.Currency
+
+	// Build and show planned transactions
+	infof("\nPreparing transactions...\n")
+	var planned []PlannedTx
+	var targets []reconcileTarget
+	var skipped []SkippedTx
+	var budgetCurrency string
+	if cf != nil {
+		budgetCurrency = cf.ISOCode
+	}
+	issues := disappearedAccounts(prevSnapshot, qAccounts, accountMapping)
+	resolver := &categoryResolver{ctx: ctx, client: yClient, cache: budget, path: ynab.CachePath(getCacheDir(), budgetID)}
+	var pastRuns []history.Run
+	pastRunsLoaded := false
+	for qNum, entry := range accountMapping {
+		yID := entry.YNABAccountID
+		rules := global.syncRules.merge(entry.syncRules)
+		tmpl, err := defaultTxSettings.merge(global.txSettings).merge(entry.txSettings).compile(cf)
 		if err != nil {
-			fmt.Printf("Error fetching YNAB accounts: %v\n", err)
-			os.Exit(1)
-		}
-		yAccountsMap := make(map[string]*ynab.Account)
-		for i := range yAccounts {
-			yAccountsMap[yAccounts[i].ID] = &yAccounts[i]
-		}
-
-		// Build and show planned transactions
-		fmt.Println("\nPreparing transactions...")
-		type PlannedTx struct {
-			QuestradeName string
-			YNABName      string
-			YNABAccountID string
-			OldBalance    float64
-			NewBalance    float64
-			Amount        float64
-		}
-		var planned []PlannedTx
-		for qNum, yID := range accountMapping {
-			// Find Questrade account
-			var qAcc *questrade.Account
-			for i := range qAccounts {
-				if qAccounts[i].Number == qNum {
-					qAcc = &qAccounts[i]
-					break
-				}
+			errorf("Error in transaction settings for %s: %v\n", qNum, err)
+			return fail(exitConfigError, fmt.Errorf("transaction settings for %s: %w", qNum, err))
+		}
+		// Find Questrade account
+		var qAcc *questrade.Account
+		for i := range qAccounts {
+			if qAccounts[i].Number == qNum {
+				qAcc = &qAccounts[i]
+				break
 			}
-			if qAcc == nil || qAcc.Balances == nil || len(qAcc.Balances.CombinedBalances) == 0 {
-				log.Printf("Skipping Questrade account %s: no balance info", qNum)
-				continue
+		}
+		if qAcc == nil {
+			msg := "not returned by Questrade"
+			if inSnapshot(prevSnapshot, qNum) {
+				msg = "disappeared since the last run"
 			}
-			qBalance := qAcc.Balances.CombinedBalances[0].TotalEquity
-			yAcc, ok := yAccountsMap[yID]
-			if !ok {
-				log.Printf("Skipping mapping for Questrade %s: YNAB account %s not found", qNum, yID)
-				continue
+			issues = append(issues, balanceIssue{QuestradeAccount: qNum, Kind: issueAccountNotFound, Message: msg, Excluded: true})
+			continue
+		}
+		qName := fmt.Sprintf("%s (%s)", qAcc.Number, qAcc.Type)
+		if qAcc.Balances == nil || len(qAcc.Balances.CombinedBalances) == 0 {
+			msg := "Questrade returned no balance"
+			if qAcc.BalancesErr != nil {
+				msg = qAcc.BalancesErr.Error()
 			}
-			yBalance := float64(yAcc.Balance) / 1000
-			diff := qBalance - yBalance
-			if diff == 0 {
-				continue
+			issues = append(issues, balanceIssue{QuestradeAccount: qName, Kind: issueMissingBalance, Message: msg, Excluded: true})
+			continue
+		}
+		qBalance := ynab.FromUnits(qAcc.Balances.CombinedBalances[0].TotalEquity)
+		yAcc, ok := yAccountsMap[yID]
+		if !ok {
+			issues = append(issues, balanceIssue{QuestradeAccount: qName, Kind: issueYNABNotFound, Message: fmt.Sprintf("YNAB account %s not found", yID), Excluded: true})
+			continue
+		}
+		issues = append(issues, checkBalance(qAcc, qName, yAcc.Name, budgetCurrency)...)
+		if qBalance == 0 && yAcc.ClearedBalance != 0 {
+			issues = append(issues, balanceIssue{QuestradeAccount: qName, YNABName: yAcc.Name, Kind: issueZeroBalance, Message: "Questrade balance is zero"})
+		}
+		run.Accounts = append(run.Accounts, history.Account{
+			QuestradeNumber:  qAcc.Number,
+			QuestradeType:    qAcc.Type,
+			QuestradeBalance: qBalance,
+			YNABAccountID:    yAcc.ID,
+			YNABName:         yAcc.Name,
+			YNABCleared:      yAcc.ClearedBalance,
+			YNABUncleared:    yAcc.UnclearedBalance,
+		})
+		// Questrade reports settled value, so compare against the cleared balance; pending
+		// uncleared entries would otherwise be counted twice once they clear
+		yBalance := yAcc.ClearedBalance
+		diff := qBalance - yBalance
+		if reason := rules.belowThreshold(yBalance, diff); diff != 0 && reason != "" {
+			// Not reconciled either, which would post the same change as an adjustment
+			skipped = append(skipped, SkippedTx{QuestradeName: qName, YNABName: yAcc.Name, Amount: diff, Reason: reason})
+			continue
+		}
+		targets = append(targets, reconcileTarget{YNABName: yAcc.Name, YNABAccountID: yAcc.ID, Balance: qBalance})
+		if diff == 0 {
+			continue
+		}
+		currency := qAcc.Balances.CombinedBalances[0].Currency
+		if currency == "" {
+			currency = budgetCurrency
+		}
+		// Categories only apply to on-budget accounts; tracking accounts have none
+		cats := global.categorySettings.merge(entry.categorySettings)
+		var contribution ynab.Milliunits
+		if yAcc.OnBudget && cats.split() {
+			if !pastRunsLoaded {
+				pastRunsLoaded = true
+				if pastRuns, err = historyStore().List(); err != nil {
+					infof("Warning: failed to read sync history: %v\n", err)
+				}
+			}
+			c, since, ok, err := contributions(ctx, qClient, pastRuns, qAcc.Number, yAcc.ID, currency, cf)
+			switch {
+			case err != nil:
+				infof("Warning: could not fetch Questrade activities for %s; contributions are not split out: %v\n", qName, err)
+			case !ok:
+				infof("Note: %s has no previous sync to measure contributions from; not splitting this time.\n", qName)
+			case c != 0:
+				infof("%s: %s in contributions since %s.\n", qName, c.Format(cf), since.Local().Format("2006-01-02 15:04"))
+				contribution = c
 			}
-			planned = append(planned, PlannedTx{
-				QuestradeName: fmt.Sprintf("%s (%s)", qAcc.Number, qAcc.Type),
-				YNABName:      yAcc.Name,
-				YNABAccountID: yAcc.ID,
-				OldBalance:    yBalance,
-				NewBalance:    qBalance,
-				Amount:        diff,
-			})
 		}
+		data := newTxTemplateData(qAcc.Number, qAcc.Type, yAcc.Name, currency, yBalance, qBalance, intraday, txDate)
+		data.Contributions = contribution.Units()
+		data.MarketChange = (diff - contribution).Units()
+		rendered, err := tmpl.render(data)
+		if err != nil {
+			errorf("Error in transaction settings for %s: %v\n", qNum, err)
+			return fail(exitConfigError, fmt.Errorf("transaction settings for %s: %w", qNum, err))
+		}
+		if yAcc.OnBudget {
+			if err := categorize(&rendered, cats, resolver, diff, contribution); err != nil {
+				printAPIError(fmt.Sprintf("Error categorizing the adjustment for %s", qName), err)
+				return fail(exitCodeFor(err), fmt.Errorf("categorizing the adjustment for %s: %w", qName, err))
+			}
+		} else {
+			// YNAB rejects a category on a tracking account, so a shared
+			// category_id template only applies to the on-budget mappings
+			rendered.CategoryID = ""
+		}
+		planned = append(planned, PlannedTx{
+			QuestradeName: qName,
+			YNABName:      yAcc.Name,
+			YNABAccountID: yAcc.ID,
+			OldBalance:    yBalance,
+			NewBalance:    qBalance,
+			Amount:        diff,
+			Uncleared:     yAcc.UnclearedBalance,
+			LargeChange:   rules.exceedsMax(yBalance, diff),
+			Transaction:   rendered,
+		})
+		run.Planned = append(run.Planned, history.Planned{
+			YNABAccountID: yAcc.ID,
+			YNABName:      yAcc.Name,
+			OldBalance:    yBalance,
+			NewBalance:    qBalance,
+			Amount:        diff,
+		})
+	}
+
+	remaining := yClient.RateLimit().Remaining()
+	doc.Planned = planned
+	doc.Skipped = skipped
+	doc.Issues = issues
+	doc.YNABRequestsRemaining = remaining
 
-		if len(planned) == 0 {
-			fmt.Println("No transactions needed; all balances match.")
-			return
+	if !opts.Reconcile {
+		targets = nil
+	}
+	for _, issue := range issues {
+		run.Warnings = append(run.Warnings, issue.String())
+	}
+	// Issues count as seen once shown to someone at a terminal or deliberately ignored;
+	// until then they are reported again on every run
+	validated = len(issues) == 0 || opts.IgnoreValidation || (!opts.AssumeYes && canPrompt())
+	if len(issues) > 0 {
+		infof("\n⚠ Balance checks found %d issue(s):\n", len(issues))
+		for _, issue := range issues {
+			infof("  ⚠ %s\n", issue)
+		}
+		infof("\n")
+	}
+	if len(skipped) > 0 && !structuredOutput() {
+		fmt.Println("Skipped (below thresholds):")
+		for _, tx := range skipped {
+			fmt.Printf("  %s → %s: delta %s, %s\n", tx.QuestradeName, tx.YNABName, tx.Amount.Format(cf), tx.Reason)
 		}
+	}
+	if len(planned) == 0 && len(targets) == 0 {
+		infof("No transactions needed; all balances match.\n")
+		return finish(history.OutcomeNoChanges, exitNoChanges)
+	}
 
+	if len(planned) == 0 {
+		infof("No transactions needed; all balances match.\n")
+	} else if !structuredOutput() {
 		fmt.Println("Planned transactions:")
 		for _, tx := range planned {
-			fmt.Printf("  %s → %s: $%.2f → $%.2f (delta: $%.2f)\n", tx.QuestradeName, tx.YNABName, tx.OldBalance, tx.NewBalance, tx.Amount)
+			fmt.Printf("  %s → %s: %s → %s (delta: %s)\n", tx.QuestradeName, tx.YNABName, tx.OldBalance.Format(cf), tx.NewBalance.Format(cf), tx.Amount.Format(cf))
+			fmt.Printf("    %s\n", tx.Transaction.describe(cf))
+			if tx.Uncleared != 0 {
+				fmt.Printf("    note: %s in uncleared transactions is left pending\n", tx.Uncleared.Format(cf))
+			}
+			if tx.LargeChange != "" {
+				fmt.Printf("    ⚠ %s; needs confirmation\n", tx.LargeChange)
+			}
 		}
+	}
+
+	if remaining >= 0 {
+		infof("\nYNAB request budget: %d of %d remaining this hour\n", remaining, yClient.RateLimit().Limit)
+	}
+
+	if len(targets) > 0 {
+		infof("Will reconcile %d YNAB account(s) against Questrade after syncing.\n", len(targets))
+	}
+
+	// Each planned transaction costs a couple of YNAB requests and reconciling a few more
+	// per account; never start a plan that would be cut off halfway by the rate limit
+	needed := adjustRequestsPerTx*len(planned) + reconcileRequestsPerAccount*len(targets)
+	if remaining >= 0 && needed > remaining {
+		infof("Refusing to sync: up to %d YNAB requests needed but only %d remain this hour. Try again later.\n", needed, remaining)
+		run.Errors = append(run.Errors, fmt.Sprintf("refused: %d YNAB requests needed, %d remaining", needed, remaining))
+		return finish(history.OutcomeRefused, exitError)
+	}
+
+	if opts.DryRun {
+		infof("\n[DRY RUN] No transactions created.\n")
+		return finish(history.OutcomeDryRun, exitChanges)
+	}
 
-		if dryRun {
-			fmt.Println("\n[DRY RUN] No transactions created.")
-			return
+	// Approval step: --yes approves up front, otherwise ask on a terminal
+	switch approvalFor(opts.AssumeYes, opts.IgnoreValidation, len(issues), canPrompt()) {
+	case approvalRefused:
+		infof("\nRefusing to apply unattended: balance checks found %d issue(s). Review them interactively or re-run with --ignore-validation.\n", len(issues))
+		run.Errors = append(run.Errors, fmt.Sprintf("refused: %d balance issue(s)", len(issues)))
+		return finish(history.OutcomeRefused, exitError)
+	case approvalGranted:
+		infof("\nApproved via --yes.\n")
+	case approvalUnavailable:
+		infof("\nCannot ask for approval without a terminal (--non-interactive or stdin not a terminal); re-run with --yes to create these transactions.\n")
+		return finish(history.OutcomeAborted, exitError)
+	case approvalAsk:
+		var response string
+		if len(issues) > 0 {
+			infof("\nReview the %d balance issue(s) above before approving.", len(issues))
+		}
+		if len(targets) > 0 {
+			infof("\nDo you want to apply these changes and reconcile in YNAB? Type 'yes' to approve: ")
+		} else {
+			infof("\nDo you want to create these transactions in YNAB? Type 'yes' to approve: ")
+		}
+		fmt.Scanln(&response)
+		if strings.ToLower(strings.TrimSpace(response)) != "yes" {
+			infof("Aborted: No transactions created.\n")
+			return finish(history.OutcomeAborted, exitNoChanges)
 		}
+	}
+	doc.Approved = true
 
-		// Manual approval step
+	// Large changes are confirmed one by one; unattended runs hold them back
+	heldBack := make(map[string]bool)
+	for _, tx := range planned {
+		if tx.LargeChange == "" || opts.AllowLargeChanges {
+			continue
+		}
+		if opts.AssumeYes {
+			infof("Holding back %s (%s): %s. Re-run interactively or with --allow-large-changes to apply it.\n", tx.YNABName, tx.Amount.Format(cf), tx.LargeChange)
+			heldBack[tx.YNABAccountID] = true
+			continue
+		}
 		var response string
-		fmt.Print("\nDo you want to create these transactions in YNAB? Type 'yes' to approve: ")
+		infof("%s changes by %s (%s → %s), %s. Type 'yes' to apply it: ", tx.YNABName, tx.Amount.Format(cf), tx.OldBalance.Format(cf), tx.NewBalance.Format(cf), tx.LargeChange)
 		fmt.Scanln(&response)
 		if strings.ToLower(strings.TrimSpace(response)) != "yes" {
-			fmt.Println("Aborted: No transactions created.")
-			return
+			infof("Holding back %s.\n", tx.YNABName)
+			heldBack[tx.YNABAccountID] = true
 		}
+	}
 
-		// Actually create transactions
-		today := time.Now().Format("2006-01-02")
-		for _, tx := range planned {
-			ynabTx := ynab.Transaction{
-				AccountID: tx.YNABAccountID,
-				Date:      today,
-				Amount:    int64(tx.Amount * 1000),
-				PayeeName: "Stock Market",
-				Memo:      "Questrade sync",
-				Cleared:   "cleared",
-				Approved:  true,
+	// Actually create transactions
+	failed := 0
+	adjustFailed := make(map[string]bool)
+	for _, tx := range planned {
+		adjustment := tx.Transaction.transaction(txDate)
+		result := TxResult{YNABName: tx.YNABName, Amount: tx.Amount}
+		if heldBack[tx.YNABAccountID] {
+			result.Error = "held back: " + tx.LargeChange
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: held back: %s", tx.YNABName, tx.LargeChange))
+			failed++
+			doc.Results = append(doc.Results, result)
+			continue
+		}
+		record := history.Transaction{Kind: history.KindSync, YNABAccountID: tx.YNABAccountID, YNABName: tx.YNABName, Date: txDate, Amount: tx.Amount}
+		// The adjustment is recomputed from the live cleared balance, in case the account
+		// changed since the preview
+		change, err := yClient.SetAccountBalance(ctx, tx.YNABAccountID, tx.NewBalance, adjustment)
+		if err != nil {
+			result.Error = err.Error()
+			record.Error = err.Error()
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", tx.YNABName, err))
+			failed++
+			adjustFailed[tx.YNABAccountID] = true
+			printAPIError(fmt.Sprintf("Error creating transaction for %s", tx.YNABName), err)
+		} else {
+			result.Amount = change.Adjustment
+			result.Created = change.Adjustment != 0
+			record.Amount = change.Adjustment
+			record.TransactionID = change.TransactionID
+			if result.Created {
+				infof("✓ Created transaction for %s: %s\n", tx.YNABName, change.Adjustment.Format(cf))
+			} else {
+				infof("✓ %s already matches; no transaction needed\n", tx.YNABName)
 			}
-			if err := yClient.CreateTransaction(ynabTx); err != nil {
-				fmt.Printf("Error creating transaction for %s: %v\n", tx.YNABName, err)
+		}
+		doc.Results = append(doc.Results, result)
+		if record.TransactionID != "" || record.Error != "" {
+			run.Transactions = append(run.Transactions, record)
+		}
+	}
+
+	reconciledChanges := 0
+	for _, t := range targets {
+		outcome := ReconcileOutcome{YNABName: t.YNABName, Balance: t.Balance}
+		if reason := reconcileSkip(t.YNABAccountID, heldBack, adjustFailed); reason != "" {
+			outcome.Error = "skipped: " + reason
+			failed++
+			doc.Reconciled = append(doc.Reconciled, outcome)
+			continue
+		}
+		res, err := yClient.ReconcileAccount(ctx, t.YNABAccountID, t.Balance, txDate)
+		outcome.ClearedBalance = res.ClearedBalance
+		outcome.Adjustment = res.Adjustment
+		outcome.Reconciled = res.Reconciled
+		if res.AdjustmentID != "" {
+			run.Transactions = append(run.Transactions, history.Transaction{
+				Kind:          history.KindReconciliation,
+				YNABAccountID: t.YNABAccountID,
+				YNABName:      t.YNABName,
+				Date:          txDate,
+				Amount:        res.Adjustment,
+				TransactionID: res.AdjustmentID,
+			})
+		}
+		if err != nil {
+			outcome.Error = err.Error()
+			failed++
+			run.Errors = append(run.Errors, fmt.Sprintf("reconciling %s: %v", t.YNABName, err))
+			printAPIError(fmt.Sprintf("Error reconciling %s", t.YNABName), err)
+		} else {
+			if res.Adjustment != 0 || res.Reconciled > 0 {
+				reconciledChanges++
+			}
+			if res.Adjustment != 0 {
+				infof("✓ Reconciled %s at %s (cleared balance was %s; adjustment %s, %d transactions)\n", t.YNABName, t.Balance.Format(cf), res.ClearedBalance.Format(cf), res.Adjustment.Format(cf), res.Reconciled)
 			} else {
-				fmt.Printf("✓ Created transaction for %s: $%.2f\n", tx.YNABName, tx.Amount)
+				infof("✓ Reconciled %s at %s (%d transactions)\n", t.YNABName, t.Balance.Format(cf), res.Reconciled)
 			}
 		}
-	},
+		doc.Reconciled = append(doc.Reconciled, outcome)
+	}
+	infof("Recorded as run %s; see 'questrade-ynab history show %s'\n", run.ID, run.ID)
+
+	total := len(planned) + len(targets)
+	switch {
+	case failed == 0 && len(run.Transactions) == 0 && reconciledChanges == 0:
+		return finish(history.OutcomeNoChanges, exitNoChanges)
+	case failed == 0:
+		return finish(history.OutcomeApplied, exitChanges)
+	case failed < total:
+		return finish(history.OutcomePartialFailure, exitPartialFailure)
+	default:
+		return finish(history.OutcomeFailed, exitError)
+	}
+}
+
+// approval is how a sync run gets permission to apply its plan
+type approval int
+
+const (
+	// approvalAsk prompts on the terminal
+	approvalAsk approval = iota
+	// approvalGranted applies the plan via --yes
+	approvalGranted
+	// approvalRefused refuses an unattended run with unreviewed balance issues
+	approvalRefused
+	// approvalUnavailable aborts because nobody can be asked
+	approvalUnavailable
+)
+
+// approvalFor decides how a plan with issues balance check findings is approved.
+// --yes never approves issues on its own; that takes --ignore-validation.
+func approvalFor(assumeYes, ignoreValidation bool, issues int, interactive bool) approval {
+	switch {
+	case assumeYes && issues > 0 && !ignoreValidation:
+		return approvalRefused
+	case assumeYes:
+		return approvalGranted
+	case !interactive:
+		return approvalUnavailable
+	}
+	return approvalAsk
+}
+
+// reconcileSkip returns why the account must not be reconciled, or "" to reconcile it.
+// Reconciling posts the difference to the Questrade balance as an adjustment, which would
+// apply a held back change anyway or paper over an adjustment that failed.
+func reconcileSkip(ynabAccountID string, heldBack, adjustFailed map[string]bool) string {
+	switch {
+	case heldBack[ynabAccountID]:
+		return "balance adjustment held back"
+	case adjustFailed[ynabAccountID]:
+		return "balance adjustment failed"
+	}
+	return ""
 }
 
 func init() {
 	rootCmd.AddCommand(syncCmd)
 	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show planned transactions but do not create them")
+	syncCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Create planned transactions without asking for approval")
+	syncCmd.Flags().BoolVar(&afterCloseOnly, "after-close-only", false, "Skip the sync while TSX or NYSE is open")
+	syncCmd.Flags().BoolVar(&dateLastTradingDay, "date-last-trading-day", false, "Date transactions to the last completed trading day instead of today")
+	syncCmd.Flags().BoolVar(&reconcile, "reconcile", false, "After syncing, reconcile each mapped YNAB account against its Questrade balance")
+	syncCmd.Flags().BoolVar(&ignoreValidation, "ignore-validation", false, "Apply changes with --yes even when balance checks found issues")
+	syncCmd.Flags().BoolVar(&allowLargeChanges, "allow-large-changes", false, "Apply changes above max_delta_percent without asking for confirmation")
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import "testing"
+
+func TestReconcileSkip(t *testing.T) {
+	heldBack := map[string]bool{"held": true}
+	adjustFailed := map[string]bool{"failed": true, "held": true}
+
+	tests := []struct {
+		account string
+		want    string
+	}{
+		{"ok", ""},
+		{"held", "balance adjustment held back"},
+		{"failed", "balance adjustment failed"},
+	}
+	for _, tt := range tests {
+		if got := reconcileSkip(tt.account, heldBack, adjustFailed); got != tt.want {
+			t.Errorf("reconcileSkip(%q) = %q, want %q", tt.account, got, tt.want)
+		}
+	}
+}
+
+func TestApprovalFor(t *testing.T) {
+	tests := []struct {
+		name                                string
+		assumeYes, ignoreValidation, prompt bool
+		issues                              int
+		want                                approval
+	}{
+		{name: "--yes", assumeYes: true, want: approvalGranted},
+		{name: "--yes with issues", assumeYes: true, issues: 2, want: approvalRefused},
+		{name: "--yes with issues at a terminal", assumeYes: true, prompt: true, issues: 1, want: approvalRefused},
+		{name: "--yes --ignore-validation with issues", assumeYes: true, ignoreValidation: true, issues: 1, want: approvalGranted},
+		{name: "terminal", prompt: true, want: approvalAsk},
+		{name: "terminal with issues", prompt: true, issues: 1, want: approvalAsk},
+		{name: "--non-interactive", want: approvalUnavailable},
+		{name: "--non-interactive with issues", ignoreValidation: true, issues: 1, want: approvalUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := approvalFor(tt.assumeYes, tt.ignoreValidation, tt.issues, tt.prompt); got != tt.want {
+				t.Errorf("approvalFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanPromptNonInteractive(t *testing.T) {
+	defer func(v bool) { nonInteractive = v }(nonInteractive)
+	nonInteractive = true
+	if canPrompt() {
+		t.Error("canPrompt() = true with --non-interactive")
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+)
+
+const (
+	// accessTokenRefreshMargin refreshes access tokens slightly before they expire so a
+	// request started just before expiry does not fail midway
+	accessTokenRefreshMargin = 2 * time.Minute
+	// refreshTokenWarnWindow is how close to refresh token expiry warnings start
+	refreshTokenWarnWindow = 48 * time.Hour
+)
+
+// questradeTokenState is the Questrade token state persisted in config.json. Expiry is
+// stored as absolute timestamps so it stays meaningful across runs.
+type questradeTokenState struct {
+	RefreshToken         string
+	AccessToken          string
+	APIServer            string
+	AccessTokenExpiresAt time.Time
+	LastRefreshAt        time.Time
+}
+
+func tokenStateFromConfig(m map[string]interface{}) questradeTokenState {
+	var s questradeTokenState
+	s.RefreshToken, _ = m["questrade_refresh_token"].(string)
+	s.AccessToken, _ = m["questrade_access_token"].(string)
+	s.APIServer, _ = m["questrade_api_server"].(string)
+	s.AccessTokenExpiresAt = configTime(m, "questrade_access_token_expires_at")
+	s.LastRefreshAt = configTime(m, "questrade_last_refresh_at")
+	return s
+}
+
+func configTime(m map[string]interface{}, key string) time.Time {
+	v, _ := m[key].(string)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// RefreshTokenExpiresAt estimates when the refresh token lapses: Questrade invalidates a
+// refresh token that has not been used for a week. Zero if the last refresh is unknown.
+func (s questradeTokenState) RefreshTokenExpiresAt() time.Time {
+	if s.LastRefreshAt.IsZero() {
+		return time.Time{}
+	}
+	return s.LastRefreshAt.Add(questrade.RefreshTokenLifetime)
+}
+
+// accessTokenUsable reports whether the cached access token is worth using as of now.
+// A legacy config without an absolute expiry is treated as usable and validated live.
+func (s questradeTokenState) accessTokenUsable(now time.Time) bool {
+	if s.AccessToken == "" || s.APIServer == "" {
+		return false
+	}
+	if s.AccessTokenExpiresAt.IsZero() {
+		return true
+	}
+	return now.Add(accessTokenRefreshMargin).Before(s.AccessTokenExpiresAt)
+}
+
+// warnings describes upcoming or past token expiries as of now
+func (s questradeTokenState) warnings(now time.Time) []string {
+	var w []string
+	if s.RefreshToken == "" {
+		return append(w, "no Questrade refresh token configured")
+	}
+	if exp := s.RefreshTokenExpiresAt(); !exp.IsZero() {
+		switch left := exp.Sub(now); {
+		case left <= 0:
+			w = append(w, fmt.Sprintf("Questrade refresh token likely expired at %s; generate a new one and run 'auth set-token'", exp.Local().Format(time.RFC1123)))
+		case left < refreshTokenWarnWindow:
+			w = append(w, fmt.Sprintf("Questrade refresh token expires in %s; run 'auth keepalive'", left.Round(time.Minute)))
+		}
+	}
+	return w
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// templateText is a text/template string. In JSON it may also be written as a bare
+// boolean or number, e.g. "approved": false.
+type templateText string
+
+func (t *templateText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = templateText(s)
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case bool, float64:
+		*t = templateText(bytes.TrimSpace(data))
+		return nil
+	}
+	return fmt.Errorf("expected a template string, got %s", data)
+}
+
+// txSettings are the fields of the balance adjustments sync posts. Each is a Go
+// text/template evaluated with txTemplateData. They are set globally under "sync" in
+// config.json and per mapping in mappings.json; empty fields inherit the global value,
+// then the default.
+type txSettings struct {
+	Payee    templateText `json:"payee,omitempty" yaml:"payee,omitempty"`
+	Memo     templateText `json:"memo,omitempty" yaml:"memo,omitempty"`
+	Cleared  templateText `json:"cleared,omitempty" yaml:"cleared,omitempty"`
+	Approved templateText `json:"approved,omitempty" yaml:"approved,omitempty"`
+	// FlagColor renders to one of ynab.FlagColors, or empty for no flag
+	FlagColor templateText `json:"flag_color,omitempty" yaml:"flag_color,omitempty"`
+	// CategoryID renders to a YNAB category ID, or empty to leave the adjustment
+	// uncategorized
+	CategoryID templateText `json:"category_id,omitempty" yaml:"category_id,omitempty"`
+}
+
+// defaultTxSettings are the settings sync used before they were configurable
+var defaultTxSettings = txSettings{
+	Payee:    "Stock Market",
+	Memo:     "Questrade sync{{if .Intraday}} (intraday){{end}}",
+	Cleared:  ynab.ClearedStatusCleared,
+	Approved: "true",
+}
+
+func (s txSettings) isZero() bool {
+	return s == txSettings{}
+}
+
+// merge returns s with every field set in override replaced
+func (s txSettings) merge(override txSettings) txSettings {
+	set := func(dst *templateText, v templateText) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&s.Payee, override.Payee)
+	set(&s.Memo, override.Memo)
+	set(&s.Cleared, override.Cleared)
+	set(&s.Approved, override.Approved)
+	set(&s.FlagColor, override.FlagColor)
+	set(&s.CategoryID, override.CategoryID)
+	return s
+}
+
+// txTemplateData is what the templates can refer to. Amounts are in currency units.
+type txTemplateData struct {
+	AccountNumber string
+	AccountType   string
+	YNABAccount   string
+	Currency      string
+	OldBalance    float64
+	NewBalance    float64
+	Delta         float64
+	// PercentChange is the signed change relative to OldBalance; 0 when OldBalance is zero
+	PercentChange float64
+	// Contributions is the part of Delta from deposits and withdrawals when
+	// split_contributions is on; MarketChange is the rest
+	Contributions float64
+	MarketChange  float64
+	Intraday      bool
+	Date          string
+}
+
+func newTxTemplateData(number, accountType, ynabName, currency string, old, new ynab.Milliunits, intraday bool, date string) txTemplateData {
+	d := txTemplateData{
+		AccountNumber: number,
+		AccountType:   accountType,
+		YNABAccount:   ynabName,
+		Currency:      currency,
+		OldBalance:    old.Units(),
+		NewBalance:    new.Units(),
+		Delta:         (new - old).Units(),
+		MarketChange:  (new - old).Units(),
+		Intraday:      intraday,
+		Date:          date,
+	}
+	if old != 0 {
+		d.PercentChange = float64(new-old) / math.Abs(float64(old)) * 100
+	}
+	return d
+}
+
+// txTemplates are compiled txSettings
+type txTemplates struct {
+	payee, memo, cleared, approved, flagColor, categoryID *template.Template
+}
+
+// renderedTx is the result of executing txTemplates for one adjustment
+type renderedTx struct {
+	Payee      string `json:"payee" yaml:"payee"`
+	Memo       string `json:"memo,omitempty" yaml:"memo,omitempty"`
+	Cleared    string `json:"cleared" yaml:"cleared"`
+	Approved   bool   `json:"approved" yaml:"approved"`
+	FlagColor  string `json:"flag_color,omitempty" yaml:"flag_color,omitempty"`
+	CategoryID string `json:"category_id,omitempty" yaml:"category_id,omitempty"`
+	// CategoryName is set when CategoryID came from gain_category or loss_category
+	CategoryName string `json:"category,omitempty" yaml:"category,omitempty"`
+	// Subtransactions split the adjustment into contribution and market-movement lines
+	Subtransactions []ynab.SubTransaction `json:"subtransactions,omitempty" yaml:"subtransactions,omitempty"`
+	// SplitNames are the category names of Subtransactions, for display
+	SplitNames []string `json:"-" yaml:"-"`
+}
+
+// compile parses the settings. Besides the standard functions, templates can use abs,
+// money (formats an amount in the budget's currency format) and percent.
+func (s txSettings) compile(cf *ynab.CurrencyFormat) (*txTemplates, error) {
+	funcs := template.FuncMap{
+		"abs":     math.Abs,
+		"money":   func(v float64) string { return ynab.FromUnits(v).Format(cf) },
+		"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	}
+	var t txTemplates
+	for _, f := range []struct {
+		name string
+		text templateText
+		dst  **template.Template
+	}{
+		{"payee", s.Payee, &t.payee},
+		{"memo", s.Memo, &t.memo},
+		{"cleared", s.Cleared, &t.cleared},
+		{"approved", s.Approved, &t.approved},
+		{"flag_color", s.FlagColor, &t.flagColor},
+		{"category_id", s.CategoryID, &t.categoryID},
+	} {
+		tmpl, err := template.New(f.name).Funcs(funcs).Option("missingkey=error").Parse(string(f.text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", f.name, err)
+		}
+		*f.dst = tmpl
+	}
+	return &t, nil
+}
+
+// render executes the templates and checks the results are values YNAB accepts
+func (t *txTemplates) render(data txTemplateData) (renderedTx, error) {
+	var r renderedTx
+	exec := func(tmpl *template.Template) (string, error) {
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+	var err error
+	if r.Payee, err = exec(t.payee); err != nil {
+		return r, err
+	}
+	if len([]rune(r.Payee)) > 200 {
+		return r, fmt.Errorf("payee template: %q is longer than YNAB's 200 characters", r.Payee)
+	}
+	if r.Memo, err = exec(t.memo); err != nil {
+		return r, err
+	}
+	if len([]rune(r.Memo)) > 500 {
+		return r, fmt.Errorf("memo template: result is longer than YNAB's 500 characters")
+	}
+
+	if r.Cleared, err = exec(t.cleared); err != nil {
+		return r, err
+	}
+	r.Cleared = strings.ToLower(r.Cleared)
+	switch r.Cleared {
+	case ynab.ClearedStatusCleared, ynab.ClearedStatusReconciled:
+	case ynab.ClearedStatusUncleared:
+		// The next sync would see the cleared balance unchanged and post the change again
+		return r, fmt.Errorf("cleared template: %q is not supported; sync compares against the cleared balance, so uncleared adjustments would be repeated every run", r.Cleared)
+	default:
+		return r, fmt.Errorf("cleared template: %q is not %s or %s", r.Cleared, ynab.ClearedStatusCleared, ynab.ClearedStatusReconciled)
+	}
+
+	approved, err := exec(t.approved)
+	if err != nil {
+		return r, err
+	}
+	if r.Approved, err = strconv.ParseBool(approved); err != nil {
+		return r, fmt.Errorf("approved template: %q is not true or false", approved)
+	}
+
+	if r.FlagColor, err = exec(t.flagColor); err != nil {
+		return r, err
+	}
+	r.FlagColor = strings.ToLower(r.FlagColor)
+	if r.FlagColor != "" && !validFlagColor(r.FlagColor) {
+		return r, fmt.Errorf("flag_color template: %q is not one of %s", r.FlagColor, strings.Join(ynab.FlagColors, ", "))
+	}
+
+	if r.CategoryID, err = exec(t.categoryID); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func validFlagColor(c string) bool {
+	for _, f := range ynab.FlagColors {
+		if c == f {
+			return true
+		}
+	}
+	return false
+}
+
+// describe summarizes the settings for the sync preview, with split amounts
+// in the budget's currency format
+func (r renderedTx) describe(cf *ynab.CurrencyFormat) string {
+	parts := []string{fmt.Sprintf("payee %q", r.Payee)}
+	if r.Memo != "" {
+		parts = append(parts, fmt.Sprintf("memo %q", r.Memo))
+	}
+	parts = append(parts, r.Cleared)
+	if !r.Approved {
+		parts = append(parts, "unapproved")
+	}
+	if r.FlagColor != "" {
+		parts = append(parts, r.FlagColor+" flag")
+	}
+	switch {
+	case r.CategoryName != "":
+		parts = append(parts, "category "+r.CategoryName)
+	case r.CategoryID != "":
+		parts = append(parts, "category "+r.CategoryID)
+	}
+	for i, sub := range r.Subtransactions {
+		if sub.Amount == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s %s", strings.ToLower(sub.Memo), sub.Amount.Format(cf))
+		if i < len(r.SplitNames) && r.SplitNames[i] != "" {
+			line += " to " + r.SplitNames[i]
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// transaction returns the YNAB transaction template SetAccountBalance posts
+func (r renderedTx) transaction(date string) ynab.Transaction {
+	return ynab.Transaction{
+		Date:       date,
+		PayeeName:  r.Payee,
+		Memo:       r.Memo,
+		Cleared:    r.Cleared,
+		Approved:   r.Approved,
+		FlagColor:  r.FlagColor,
+		CategoryID: r.CategoryID,
+		// SetAccountBalance balances the lines against the live adjustment
+		Subtransactions: r.Subtransactions,
+	}
+}
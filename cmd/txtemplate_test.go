@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+func TestTemplateTextUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    templateText
+		wantErr bool
+	}{
+		{json: `"{{.AccountType}}"`, want: "{{.AccountType}}"},
+		{json: `""`, want: ""},
+		{json: `true`, want: "true"},
+		{json: `false`, want: "false"},
+		{json: `3`, want: "3"},
+		{json: `-1.5`, want: "-1.5"},
+		{json: `null`, want: ""},
+		{json: `["red"]`, wantErr: true},
+		{json: `{"payee": "x"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got templateText
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, want %q", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestTxSettingsJSON(t *testing.T) {
+	var s txSettings
+	if err := json.Unmarshal([]byte(`{"approved": false, "flag_color": "{{if .Intraday}}yellow{{end}}"}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	merged := defaultTxSettings.merge(s)
+	if merged.Approved != "false" || merged.FlagColor != "{{if .Intraday}}yellow{{end}}" || merged.Payee != defaultTxSettings.Payee {
+		t.Errorf("merged settings = %+v", merged)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := newTxTemplateData("123", "TFSA", "Brokerage", "CAD", 1000000, 1100000, true, "2026-01-05")
+
+	tests := []struct {
+		name     string
+		settings txSettings
+		check    func(renderedTx) bool
+		wantErr  string
+	}{
+		{
+			name: "defaults",
+			check: func(r renderedTx) bool {
+				return r.Payee == "Stock Market" && r.Memo == "Questrade sync (intraday)" && r.Cleared == "cleared" && r.Approved
+			},
+		},
+		{
+			name:     "template data and functions",
+			settings: txSettings{Memo: "{{.AccountType}} {{money .OldBalance}} → {{money .NewBalance}} ({{percent .PercentChange}})"},
+			check:    func(r renderedTx) bool { return r.Memo == "TFSA $1,000.00 → $1,100.00 (10.0%)" },
+		},
+		{
+			name:     "values are trimmed and lowercased",
+			settings: txSettings{Cleared: " Reconciled ", Approved: "false", FlagColor: "{{if gt (abs .PercentChange) 5.0}}RED{{end}}"},
+			check:    func(r renderedTx) bool { return r.Cleared == "reconciled" && !r.Approved && r.FlagColor == "red" },
+		},
+		{
+			name:     "empty flag color",
+			settings: txSettings{FlagColor: "{{if .Intraday}}{{end}}"},
+			check:    func(r renderedTx) bool { return r.FlagColor == "" },
+		},
+		{
+			name:     "category ID",
+			settings: txSettings{CategoryID: "{{if eq .AccountType \"TFSA\"}}cat-1{{end}}"},
+			check:    func(r renderedTx) bool { return r.CategoryID == "cat-1" },
+		},
+		{name: "payee too long", settings: txSettings{Payee: templateText(strings.Repeat("p", 201))}, wantErr: "longer than YNAB's 200"},
+		{name: "memo too long", settings: txSettings{Memo: templateText(strings.Repeat("é", 501))}, wantErr: "longer than YNAB's 500"},
+		{name: "uncleared", settings: txSettings{Cleared: "uncleared"}, wantErr: "not supported"},
+		{name: "unknown cleared status", settings: txSettings{Cleared: "pending"}, wantErr: "is not cleared or reconciled"},
+		{name: "approved not a boolean", settings: txSettings{Approved: "maybe"}, wantErr: "approved template"},
+		{name: "unknown flag color", settings: txSettings{FlagColor: "pink"}, wantErr: "flag_color template"},
+		{name: "unknown field", settings: txSettings{Payee: "{{.Nope}}"}, wantErr: "Nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := defaultTxSettings.merge(tt.settings).compile(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := tmpl.render(data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("render() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !tt.check(r) {
+				t.Errorf("render() = %+v, %v", r, err)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := defaultTxSettings.merge(txSettings{Payee: "{{if .Intraday}}"}).compile(nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid payee template") {
+		t.Errorf("compile() error = %v, want an invalid payee template", err)
+	}
+}
+
+func TestNewTxTemplateData(t *testing.T) {
+	d := newTxTemplateData("1", "TFSA", "Brokerage", "CAD", -2000000, -1000000, false, "2026-01-05")
+	if d.Delta != 1000 || d.PercentChange != 50 || d.MarketChange != 1000 {
+		t.Errorf("newTxTemplateData() = %+v, want delta 1000 and +50%%", d)
+	}
+	if d := newTxTemplateData("1", "TFSA", "Brokerage", "CAD", 0, ynab.FromUnits(10), false, ""); d.PercentChange != 0 {
+		t.Errorf("PercentChange from zero = %v, want 0", d.PercentChange)
+	}
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/history"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	undoReverse bool
+	undoDryRun  bool
+	undoYes     bool
+)
+
+// Undo actions
+const (
+	undoActionDelete  = "delete"
+	undoActionReverse = "reverse"
+)
+
+// undoDocument is the structured form of an undo emitted with --output json|yaml
+type undoDocument struct {
+	RunID        string                `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	UndoOf       string                `json:"undo_of" yaml:"undo_of"`
+	Action       string                `json:"action" yaml:"action"`
+	DryRun       bool                  `json:"dry_run" yaml:"dry_run"`
+	Pending      []history.Transaction `json:"pending" yaml:"pending"`
+	Approved     bool                  `json:"approved" yaml:"approved"`
+	Transactions []history.Transaction `json:"transactions,omitempty" yaml:"transactions,omitempty"`
+}
+
+// undoTarget returns the run to undo: the one matching id, or by default the most recent
+// sync run that still has outstanding transactions
+func undoTarget(runs []history.Run, id string) (*history.Run, error) {
+	if id != "" {
+		return historyStore().Get(id)
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].UndoOf == "" && len(history.Outstanding(runs, &runs[i])) > 0 {
+			return &runs[i], nil
+		}
+	}
+	return nil, history.ErrNotFound
+}
+
+// reversalOf returns a transaction dated date that offsets orig exactly: the same payee,
+// category and split lines with every amount negated
+func reversalOf(orig ynab.Transaction, date, memo string) ynab.Transaction {
+	rev := ynab.Transaction{
+		AccountID: orig.AccountID,
+		Date:      date,
+		Amount:    -orig.Amount,
+		PayeeID:   orig.PayeeID,
+		PayeeName: orig.PayeeName,
+		Memo:      memo,
+		Cleared:   ynab.ClearedStatusCleared,
+		Approved:  true,
+	}
+	for _, sub := range orig.Subtransactions {
+		if sub.Deleted {
+			continue
+		}
+		sub.Amount = -sub.Amount
+		rev.Subtransactions = append(rev.Subtransactions, sub)
+	}
+	// A split's category is set per line
+	if len(rev.Subtransactions) == 0 {
+		rev.CategoryID = orig.CategoryID
+	}
+	return rev
+}
+
+var syncUndoCmd = &cobra.Command{
+	Use:   "undo [run-id]",
+	Short: "Delete or reverse the YNAB transactions a previous sync created",
+	Long: `Delete (or, with --reverse, offset) the YNAB transactions a previous sync run created.
+
+Without a run ID the most recent run with transactions still in place is undone. A unique
+prefix of the run ID is enough; see 'questrade-ynab history list'. The undo is recorded in
+the history, and running it again only touches transactions not already undone.
+
+Exit codes:
+  0  nothing to undo, or aborted
+  1  error
+  2  transactions undone (or pending, with --dry-run)
+  3  partial failure: some transactions could not be undone
+  5  configuration error`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		startedAt := time.Now()
+		if err := loadConfig(); err != nil {
+			errorf("Error loading config: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		ynabToken := viper.GetString("ynab_access_token")
+		if ynabToken == "" {
+			errorf("Missing required YNAB configuration. Please run 'questrade-ynab auth set' first\n")
+			os.Exit(exitConfigError)
+		}
+
+		store := historyStore()
+		runs, err := store.List()
+		if err != nil {
+			errorf("Error reading history: %v\n", err)
+			os.Exit(exitError)
+		}
+		var id string
+		if len(args) > 0 {
+			id = args[0]
+		}
+		target, err := undoTarget(runs, id)
+		if err != nil {
+			if errors.Is(err, history.ErrNotFound) && id == "" {
+				infof("No sync run has transactions left to undo.\n")
+				os.Exit(exitNoChanges)
+			}
+			if errors.Is(err, history.ErrNotFound) {
+				errorf("No sync run matches %q; see 'questrade-ynab history list'\n", id)
+			} else {
+				errorf("Error reading history: %v\n", err)
+			}
+			os.Exit(exitError)
+		}
+		if target.UndoOf != "" {
+			errorf("Run %s is itself an undo of %s and cannot be undone\n", target.ID, target.UndoOf)
+			os.Exit(exitError)
+		}
+
+		action := undoActionDelete
+		if undoReverse {
+			action = undoActionReverse
+		}
+		pending := history.Outstanding(runs, target)
+		doc := undoDocument{UndoOf: target.ID, Action: action, DryRun: undoDryRun, Pending: pending}
+		emit := func() {
+			if structuredOutput() {
+				if doc.Pending == nil {
+					doc.Pending = []history.Transaction{}
+				}
+				if err := printDocument(doc); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(exitError)
+				}
+			}
+		}
+
+		if len(pending) == 0 {
+			infof("Run %s has no transactions left to undo.\n", target.ID)
+			emit()
+			os.Exit(exitNoChanges)
+		}
+
+		cf := historyCurrencyFormat(target.BudgetID)
+		if !structuredOutput() {
+			verb := "Delete"
+			if action == undoActionReverse {
+				verb = "Offset"
+			}
+			fmt.Printf("Run %s (%s) created these transactions:\n", target.ID, target.StartedAt.Local().Format(time.RFC1123))
+			for _, tx := range pending {
+				fmt.Printf("  %s %s %s %s (%s)\n", verb, tx.Date, tx.YNABName, tx.Amount.Format(cf), tx.Kind)
+			}
+		}
+
+		if undoDryRun {
+			infof("\n[DRY RUN] No transactions changed.\n")
+			emit()
+			os.Exit(exitChanges)
+		}
+
+		switch {
+		case undoYes:
+			infof("\nApproved via --yes.\n")
+		case !canPrompt():
+			infof("\nCannot ask for approval without a terminal (--non-interactive or stdin not a terminal); re-run with --yes to undo these transactions.\n")
+			emit()
+			os.Exit(exitError)
+		default:
+			var response string
+			infof("\nDo you want to %s these transactions in YNAB? Type 'yes' to approve: ", action)
+			fmt.Scanln(&response)
+			if strings.ToLower(strings.TrimSpace(response)) != "yes" {
+				infof("Aborted: No transactions changed.\n")
+				emit()
+				os.Exit(exitNoChanges)
+			}
+		}
+		doc.Approved = true
+
+		yClient := ynab.NewClient(ynabToken, target.BudgetID)
+		undo := history.Run{
+			ID:        history.NewRunID(startedAt),
+			StartedAt: startedAt,
+			BudgetID:  target.BudgetID,
+			Currency:  target.Currency,
+			UndoOf:    target.ID,
+		}
+		today := time.Now().Format("2006-01-02")
+		failed := 0
+		for _, tx := range pending {
+			record := history.Transaction{
+				YNABAccountID: tx.YNABAccountID,
+				YNABName:      tx.YNABName,
+				Reverts:       tx.TransactionID,
+			}
+			var err error
+			if action == undoActionReverse {
+				record.Kind = history.KindReversal
+				record.Date = today
+				record.Amount = -tx.Amount
+				// Offset the transaction as it stands in YNAB, so its categories and
+				// split lines are reversed along with the amount
+				var orig, created *ynab.Transaction
+				orig, err = yClient.GetTransaction(cmd.Context(), tx.TransactionID)
+				switch {
+				case errors.Is(err, ynab.ErrNotFound) || (err == nil && orig.Deleted):
+					// Deleted by hand in YNAB: nothing is left to offset
+					infof("  %s transaction %s was already deleted\n", tx.YNABName, tx.TransactionID)
+					record.Amount = 0
+					err = nil
+				case err == nil:
+					record.Amount = -orig.Amount
+					created, err = yClient.CreateTransactionContext(cmd.Context(), reversalOf(*orig, today, "Undo Questrade sync "+target.ID))
+					if err == nil {
+						record.TransactionID = created.ID
+					}
+				}
+			} else {
+				record.Kind = history.KindDeleted
+				record.Date = tx.Date
+				record.Amount = tx.Amount
+				err = yClient.DeleteTransaction(cmd.Context(), tx.TransactionID)
+				// Already deleted by hand in YNAB: the goal is met
+				if errors.Is(err, ynab.ErrNotFound) {
+					infof("  %s transaction %s was already deleted\n", tx.YNABName, tx.TransactionID)
+					err = nil
+				}
+			}
+			if err != nil {
+				record.Error = err.Error()
+				failed++
+				undo.Errors = append(undo.Errors, fmt.Sprintf("%s: %v", tx.YNABName, err))
+				printAPIError(fmt.Sprintf("Error undoing transaction for %s", tx.YNABName), err)
+			} else {
+				infof("✓ Undid %s transaction for %s: %s\n", tx.Date, tx.YNABName, tx.Amount.Format(cf))
+			}
+			undo.Transactions = append(undo.Transactions, record)
+		}
+
+		switch {
+		case failed == 0:
+			undo.Outcome = history.OutcomeApplied
+		case failed < len(pending):
+			undo.Outcome = history.OutcomePartialFailure
+		default:
+			undo.Outcome = history.OutcomeFailed
+		}
+		undo.FinishedAt = time.Now()
+		if err := store.Append(undo); err != nil {
+			infof("Warning: failed to record undo in history: %v\n", err)
+		}
+		doc.RunID = undo.ID
+		doc.Transactions = undo.Transactions
+		emit()
+
+		switch undo.Outcome {
+		case history.OutcomeApplied:
+			os.Exit(exitChanges)
+		case history.OutcomePartialFailure:
+			os.Exit(exitPartialFailure)
+		default:
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncUndoCmd)
+	syncUndoCmd.Flags().BoolVar(&undoReverse, "reverse", false, "Post offsetting transactions instead of deleting")
+	syncUndoCmd.Flags().BoolVar(&undoDryRun, "dry-run", false, "Show what would be undone without changing anything")
+	syncUndoCmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "Undo without asking for approval")
+}
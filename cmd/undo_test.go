@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/history"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+func TestUndoTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	created := []history.Transaction{{Kind: history.KindSync, TransactionID: "tx1"}}
+	runs := []history.Run{
+		{ID: "20260105T170000Z-aaaa", Transactions: created},
+		{ID: "20260106T170000Z-bbbb", Transactions: []history.Transaction{{Kind: history.KindSync, TransactionID: "tx2"}}},
+		{ID: "20260106T180000Z-cccc", UndoOf: "20260106T170000Z-bbbb", Transactions: []history.Transaction{{Kind: history.KindDeleted, Reverts: "tx2"}}},
+		{ID: "20260107T170000Z-dddd", Outcome: history.OutcomeNoChanges},
+	}
+	for _, r := range runs {
+		if err := historyStore().Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		runs    []history.Run
+		id      string
+		want    string
+		wantErr error
+	}{
+		{name: "latest run with transactions left", runs: runs, want: "20260105T170000Z-aaaa"},
+		{name: "nothing left", runs: runs[1:], wantErr: history.ErrNotFound},
+		{name: "no history", wantErr: history.ErrNotFound},
+		{name: "by prefix", runs: runs, id: "20260106T17", want: "20260106T170000Z-bbbb"},
+		{name: "unknown ID", runs: runs, id: "2025", wantErr: history.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := undoTarget(tt.runs, tt.id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("undoTarget() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.ID != tt.want {
+				t.Errorf("undoTarget() = %+v, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReversalOf(t *testing.T) {
+	tests := []struct {
+		name string
+		orig ynab.Transaction
+		want ynab.Transaction
+	}{
+		{
+			name: "categorized",
+			orig: ynab.Transaction{ID: "tx", AccountID: "a", Date: "2026-01-05", Amount: 150000, PayeeID: "p", PayeeName: "Investment Gain", CategoryID: "gains", Memo: "TFSA", Cleared: ynab.ClearedStatusReconciled, FlagColor: "red"},
+			want: ynab.Transaction{AccountID: "a", Date: "2026-01-09", Amount: -150000, PayeeID: "p", PayeeName: "Investment Gain", CategoryID: "gains", Memo: "Undo", Cleared: ynab.ClearedStatusCleared, Approved: true},
+		},
+		{
+			name: "split",
+			orig: ynab.Transaction{AccountID: "a", Amount: 1500000, PayeeName: "Stock Market", CategoryID: "split", Subtransactions: []ynab.SubTransaction{
+				{Amount: 1000000, PayeeName: "Contribution", CategoryID: "rta", Memo: "Contribution"},
+				{Amount: 999, CategoryID: "gains", Deleted: true},
+				{Amount: 500000, CategoryID: "gains", Memo: "Market movement"},
+			}},
+			want: ynab.Transaction{AccountID: "a", Date: "2026-01-09", Amount: -1500000, PayeeName: "Stock Market", Memo: "Undo", Cleared: ynab.ClearedStatusCleared, Approved: true, Subtransactions: []ynab.SubTransaction{
+				{Amount: -1000000, PayeeName: "Contribution", CategoryID: "rta", Memo: "Contribution"},
+				{Amount: -500000, CategoryID: "gains", Memo: "Market movement"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reversalOf(tt.orig, "2026-01-09", "Undo"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reversalOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/snapshot"
+)
+
+// Balance issue kinds
+const (
+	issueAccountNotFound    = "account_not_found"
+	issueMissingBalance     = "missing_balance"
+	issueYNABNotFound       = "ynab_account_not_found"
+	issueZeroBalance        = "zero_balance"
+	issueNotRealTime        = "not_real_time"
+	issueCurrencyMismatch   = "currency_mismatch"
+	issueAccountDisappeared = "account_disappeared"
+)
+
+// balanceIssue is a problem found while checking Questrade data before syncing it.
+// Excluded issues leave the account out of the sync; the others are suspicious values
+// that are synced only after review.
+type balanceIssue struct {
+	QuestradeAccount string `json:"questrade_account" yaml:"questrade_account"`
+	YNABName         string `json:"ynab_account,omitempty" yaml:"ynab_account,omitempty"`
+	Kind             string `json:"kind" yaml:"kind"`
+	Message          string `json:"message" yaml:"message"`
+	Excluded         bool   `json:"excluded" yaml:"excluded"`
+}
+
+func (i balanceIssue) String() string {
+	s := i.QuestradeAccount
+	if i.YNABName != "" {
+		s += " → " + i.YNABName
+	}
+	s += ": " + i.Message
+	if i.Excluded {
+		s += " (not synced)"
+	}
+	return s
+}
+
+// checkBalance flags combined balances that are not real-time or not in the budget's
+// currency. qAcc must have balances.
+func checkBalance(qAcc *questrade.Account, qName, ynabName, budgetCurrency string) []balanceIssue {
+	var issues []balanceIssue
+	combined := qAcc.Balances.CombinedBalances[0]
+	if !combined.IsRealTime {
+		issues = append(issues, balanceIssue{
+			QuestradeAccount: qName,
+			YNABName:         ynabName,
+			Kind:             issueNotRealTime,
+			Message:          "balance is not real-time and may be stale",
+		})
+	}
+	if budgetCurrency != "" && combined.Currency != "" && !strings.EqualFold(combined.Currency, budgetCurrency) {
+		issues = append(issues, balanceIssue{
+			QuestradeAccount: qName,
+			YNABName:         ynabName,
+			Kind:             issueCurrencyMismatch,
+			Message:          fmt.Sprintf("balance is in %s but the budget is in %s", combined.Currency, budgetCurrency),
+		})
+	}
+	return issues
+}
+
+// warnMissingBalances warns about accounts whose balances could not be fetched, for
+// commands that show balances without validating them
+func warnMissingBalances(accounts []questrade.Account) {
+	for _, acc := range accounts {
+		if acc.BalancesErr != nil {
+			errorf("Warning: %v\n", acc.BalancesErr)
+		}
+	}
+}
+
+// disappearedAccounts flags accounts in the previous snapshot that Questrade no longer
+// returns. Mapped accounts are reported by the sync loop instead.
+func disappearedAccounts(prev *snapshot.Snapshot, qAccounts []questrade.Account, mapping map[string]mappingEntry) []balanceIssue {
+	if prev == nil {
+		return nil
+	}
+	current := make(map[string]bool, len(qAccounts))
+	for _, acc := range qAccounts {
+		current[acc.Number] = true
+	}
+	var issues []balanceIssue
+	for _, acc := range prev.Accounts {
+		if current[acc.Number] {
+			continue
+		}
+		if _, mapped := mapping[acc.Number]; mapped {
+			continue
+		}
+		issues = append(issues, balanceIssue{
+			QuestradeAccount: fmt.Sprintf("%s (%s)", acc.Number, acc.Type),
+			Kind:             issueAccountDisappeared,
+			Message:          fmt.Sprintf("returned on %s but missing now", prev.TakenAt.Local().Format("2006-01-02 15:04")),
+		})
+	}
+	return issues
+}
+
+// lastSnapshot returns the most recent validated balance snapshot, or nil if there is
+// none
+func lastSnapshot() *snapshot.Snapshot {
+	snaps, err := snapshotStore().List()
+	if err != nil {
+		return nil
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if !snaps[i].Unvalidated {
+			return &snaps[i]
+		}
+	}
+	return nil
+}
+
+// inSnapshot reports whether the snapshot includes the account
+func inSnapshot(snap *snapshot.Snapshot, number string) bool {
+	if snap == nil {
+		return false
+	}
+	for _, acc := range snap.Accounts {
+		if acc.Number == number {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/snapshot"
+)
+
+func TestDisappearedAccounts(t *testing.T) {
+	prev := &snapshot.Snapshot{
+		TakenAt: time.Date(2026, 1, 5, 17, 0, 0, 0, time.UTC),
+		Accounts: []snapshot.Account{
+			{Number: "1", Type: "TFSA"},
+			{Number: "2", Type: "RRSP"},
+			{Number: "3", Type: "Margin"},
+		},
+	}
+	current := []questrade.Account{{Number: "1"}, {Number: "4"}}
+	// 2 is mapped and reported by the sync loop; 3 is unmapped and gone
+	mapping := map[string]mappingEntry{"1": {YNABAccountID: "a"}, "2": {YNABAccountID: "b"}}
+
+	issues := disappearedAccounts(prev, current, mapping)
+	if len(issues) != 1 || issues[0].QuestradeAccount != "3 (Margin)" || issues[0].Kind != issueAccountDisappeared {
+		t.Errorf("disappearedAccounts() = %+v, want one issue for 3 (Margin)", issues)
+	}
+	if issues := disappearedAccounts(nil, current, mapping); issues != nil {
+		t.Errorf("disappearedAccounts(nil) = %+v, want none", issues)
+	}
+}
+
+func TestLastSnapshotPrefersValidated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if got := lastSnapshot(); got != nil {
+		t.Fatalf("lastSnapshot() with no snapshots = %+v, want nil", got)
+	}
+	base := time.Date(2026, 1, 5, 17, 0, 0, 0, time.UTC)
+	for i, validated := range []bool{false, true, false} {
+		snap := snapshot.Snapshot{TakenAt: base.Add(time.Duration(i) * time.Hour), Source: snapshot.SourceSync, Unvalidated: !validated}
+		if err := snapshotStore().Append(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := lastSnapshot(); got == nil || !got.TakenAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("lastSnapshot() = %+v, want the validated snapshot", got)
+	}
+}
+
+func TestCheckBalance(t *testing.T) {
+	acc := &questrade.Account{Number: "1", Balances: &questrade.AccountBalances{
+		CombinedBalances: []questrade.PerCurrencyBalance{{Currency: "USD", IsRealTime: false}},
+	}}
+	issues := checkBalance(acc, "1 (TFSA)", "TFSA", "CAD")
+	if len(issues) != 2 || issues[0].Kind != issueNotRealTime || issues[1].Kind != issueCurrencyMismatch {
+		t.Errorf("checkBalance() = %+v, want not real-time and currency mismatch", issues)
+	}
+	acc.Balances.CombinedBalances[0] = questrade.PerCurrencyBalance{Currency: "cad", IsRealTime: true}
+	if issues := checkBalance(acc, "1 (TFSA)", "TFSA", "CAD"); len(issues) != 0 {
+		t.Errorf("checkBalance() = %+v, want none", issues)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package atomicfile
+
+// syncDir is a no-op where directories cannot be synced; the rename is still atomic
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package atomicfile
+
+import "os"
+
+// syncDir flushes the directory entry of a rename in dir to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
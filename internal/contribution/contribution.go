@@ -0,0 +1,166 @@
+// Package contribution tallies deposits into registered Questrade accounts and compares
+// them with the contribution room the user has available, so over-contributions across
+// several accounts of the same kind are caught before they are penalized.
+package contribution
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// Room types: the kinds of contribution room registered accounts draw from
+const (
+	RoomTFSA = "TFSA"
+	RoomRRSP = "RRSP"
+	RoomFHSA = "FHSA"
+)
+
+// RoomTypes lists every room type in display order
+var RoomTypes = []string{RoomTFSA, RoomRRSP, RoomFHSA}
+
+// RoomType returns the contribution room a Questrade account type draws from, or "" for
+// accounts without contribution limits. Spousal RRSP contributions use the
+// contributor's RRSP room.
+func RoomType(accountType string) string {
+	switch strings.ToUpper(accountType) {
+	case "TFSA":
+		return RoomTFSA
+	case "RRSP", "SRRSP":
+		return RoomRRSP
+	case "FHSA":
+		return RoomFHSA
+	}
+	return ""
+}
+
+// NormalizeRoomType returns the room type named by s case-insensitively, or ""
+func NormalizeRoomType(s string) string {
+	for _, t := range RoomTypes {
+		if strings.EqualFold(s, t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// Account is the contribution activity of one registered account. Amounts are in
+// Currency. Deposits and transfers in other currencies are kept apart in Other, since
+// room is counted in Canadian dollars at the exchange rate of the day; withdrawals in
+// them are ignored.
+type Account struct {
+	Number      string          `json:"number" yaml:"number"`
+	Type        string          `json:"type" yaml:"type"`
+	RoomType    string          `json:"room_type" yaml:"room_type"`
+	Currency    string          `json:"currency" yaml:"currency"`
+	Deposits    ynab.Milliunits `json:"deposits_milliunits" yaml:"deposits_milliunits"`
+	Withdrawals ynab.Milliunits `json:"withdrawals_milliunits" yaml:"withdrawals_milliunits"`
+	// TransfersIn are the cash values of transfers into the account. They are not
+	// counted as deposits: a transfer from a non-registered account uses room, while a
+	// direct transfer from an account of the same type does not.
+	TransfersIn ynab.Milliunits `json:"transfers_in_milliunits" yaml:"transfers_in_milliunits"`
+	// Other are deposits and transfers in other currencies, by currency, that are not
+	// converted
+	Other map[string]ynab.Milliunits `json:"other_currency_deposits_milliunits,omitempty" yaml:"other_currency_deposits_milliunits,omitempty"`
+}
+
+// Tally sums the deposits, withdrawals and transfers in among activities. Withdrawals
+// are positive; transfers out are ignored.
+func Tally(number, accountType, currency string, activities []questrade.Activity) Account {
+	a := Account{Number: number, Type: accountType, RoomType: RoomType(accountType), Currency: currency}
+	other := func(act questrade.Activity, amount ynab.Milliunits) {
+		if a.Other == nil {
+			a.Other = make(map[string]ynab.Milliunits)
+		}
+		a.Other[act.Currency] += amount
+	}
+	for _, act := range activities {
+		amount := ynab.FromUnits(act.NetAmount)
+		switch act.Type {
+		case questrade.ActivityDeposits:
+			if act.Currency != currency {
+				other(act, amount)
+				continue
+			}
+			a.Deposits += amount
+		case questrade.ActivityTransfers:
+			if amount <= 0 {
+				continue
+			}
+			if act.Currency != currency {
+				other(act, amount)
+				continue
+			}
+			a.TransfersIn += amount
+		case questrade.ActivityWithdrawals:
+			if act.Currency == currency {
+				a.Withdrawals -= amount
+			}
+		}
+	}
+	return a
+}
+
+// Room is the contribution room of one type across all accounts drawing from it.
+// Withdrawals do not restore room within the year, so only deposits count.
+type Room struct {
+	Type        string          `json:"type" yaml:"type"`
+	Accounts    []string        `json:"accounts" yaml:"accounts"`
+	Contributed ynab.Milliunits `json:"contributed_milliunits" yaml:"contributed_milliunits"`
+	// TransfersIn are transfers into the accounts, which are not in Contributed since
+	// only some of them use room
+	TransfersIn ynab.Milliunits `json:"transfers_in_milliunits" yaml:"transfers_in_milliunits"`
+	// Limit and Remaining are nil when no limit is configured for the type
+	Limit     *ynab.Milliunits `json:"limit_milliunits,omitempty" yaml:"limit_milliunits,omitempty"`
+	Remaining *ynab.Milliunits `json:"remaining_milliunits,omitempty" yaml:"remaining_milliunits,omitempty"`
+	// Over is set when contributions exceed the limit
+	Over bool `json:"over_contributed" yaml:"over_contributed"`
+	// Unconverted is set when some deposits or transfers were in another currency and
+	// are not counted
+	Unconverted bool `json:"unconverted_deposits,omitempty" yaml:"unconverted_deposits,omitempty"`
+}
+
+// Rooms totals accounts by room type against limits, which are keyed by room type.
+// Types with neither accounts nor a limit are left out.
+func Rooms(accounts []Account, limits map[string]ynab.Milliunits) []Room {
+	byType := make(map[string]*Room)
+	get := func(t string) *Room {
+		r, ok := byType[t]
+		if !ok {
+			r = &Room{Type: t, Accounts: []string{}}
+			byType[t] = r
+		}
+		return r
+	}
+	for _, a := range accounts {
+		if a.RoomType == "" {
+			continue
+		}
+		r := get(a.RoomType)
+		r.Accounts = append(r.Accounts, a.Number)
+		r.Contributed += a.Deposits
+		r.TransfersIn += a.TransfersIn
+		if len(a.Other) > 0 {
+			r.Unconverted = true
+		}
+	}
+	for t, limit := range limits {
+		r := get(t)
+		limit := limit
+		remaining := limit - r.Contributed
+		r.Limit = &limit
+		r.Remaining = &remaining
+		r.Over = remaining < 0
+	}
+
+	var rooms []Room
+	for _, t := range RoomTypes {
+		if r, ok := byType[t]; ok {
+			sort.Strings(r.Accounts)
+			rooms = append(rooms, *r)
+		}
+	}
+	return rooms
+}
@@ -0,0 +1,127 @@
+package contribution
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+func TestTally(t *testing.T) {
+	act := func(typ, currency string, amount float64) questrade.Activity {
+		return questrade.Activity{Type: typ, Currency: currency, NetAmount: amount}
+	}
+
+	tests := []struct {
+		name       string
+		accType    string
+		activities []questrade.Activity
+		want       Account
+	}{
+		{
+			name:    "no activity",
+			accType: "TFSA",
+			want:    Account{Number: "1", Type: "TFSA", RoomType: RoomTFSA, Currency: "CAD"},
+		},
+		{
+			name:    "deposits and withdrawals",
+			accType: "TFSA",
+			activities: []questrade.Activity{
+				act(questrade.ActivityDeposits, "CAD", 5000),
+				act(questrade.ActivityDeposits, "CAD", 1000.5),
+				act(questrade.ActivityWithdrawals, "CAD", -2000),
+				act("Dividends", "CAD", 12.34),
+				act("Trades", "CAD", -4000),
+			},
+			want: Account{Number: "1", Type: "TFSA", RoomType: RoomTFSA, Currency: "CAD", Deposits: 6000500, Withdrawals: 2000000},
+		},
+		{
+			name:    "transfers in are kept apart and transfers out ignored",
+			accType: "RRSP",
+			activities: []questrade.Activity{
+				act(questrade.ActivityTransfers, "CAD", 3000),
+				act(questrade.ActivityTransfers, "CAD", -500),
+				act(questrade.ActivityTransfers, "CAD", 0),
+			},
+			want: Account{Number: "1", Type: "RRSP", RoomType: RoomRRSP, Currency: "CAD", TransfersIn: 3000000},
+		},
+		{
+			name:    "other currencies are not converted",
+			accType: "SRRSP",
+			activities: []questrade.Activity{
+				act(questrade.ActivityDeposits, "USD", 100),
+				act(questrade.ActivityTransfers, "USD", 50),
+				act(questrade.ActivityWithdrawals, "USD", -20),
+			},
+			want: Account{Number: "1", Type: "SRRSP", RoomType: RoomRRSP, Currency: "CAD", Other: map[string]ynab.Milliunits{"USD": 150000}},
+		},
+		{
+			name:       "unregistered account",
+			accType:    "Margin",
+			activities: []questrade.Activity{act(questrade.ActivityDeposits, "CAD", 100)},
+			want:       Account{Number: "1", Type: "Margin", Currency: "CAD", Deposits: 100000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tally("1", tt.accType, "CAD", tt.activities); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tally() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRooms(t *testing.T) {
+	m := func(v ynab.Milliunits) *ynab.Milliunits { return &v }
+
+	tests := []struct {
+		name     string
+		accounts []Account
+		limits   map[string]ynab.Milliunits
+		want     []Room
+	}{
+		{"nothing", nil, nil, nil},
+		{
+			name: "accounts of one type are summed",
+			accounts: []Account{
+				{Number: "2", RoomType: RoomTFSA, Deposits: 4000000, Withdrawals: 1000000},
+				{Number: "1", RoomType: RoomTFSA, Deposits: 2000000, TransfersIn: 500000},
+				{Number: "3", Type: "Margin", Deposits: 9000000},
+			},
+			limits: map[string]ynab.Milliunits{RoomTFSA: 7000000},
+			want: []Room{
+				{Type: RoomTFSA, Accounts: []string{"1", "2"}, Contributed: 6000000, TransfersIn: 500000, Limit: m(7000000), Remaining: m(1000000)},
+			},
+		},
+		{
+			name: "over-contribution",
+			accounts: []Account{
+				{Number: "1", RoomType: RoomFHSA, Deposits: 9000000},
+			},
+			limits: map[string]ynab.Milliunits{RoomFHSA: 8000000},
+			want: []Room{
+				{Type: RoomFHSA, Accounts: []string{"1"}, Contributed: 9000000, Limit: m(8000000), Remaining: m(-1000000), Over: true},
+			},
+		},
+		{
+			name: "limits without accounts and accounts without limits, in display order",
+			accounts: []Account{
+				{Number: "1", RoomType: RoomRRSP, Other: map[string]ynab.Milliunits{"USD": 100000}},
+			},
+			limits: map[string]ynab.Milliunits{RoomFHSA: 8000000, RoomTFSA: 7000000},
+			want: []Room{
+				{Type: RoomTFSA, Accounts: []string{}, Limit: m(7000000), Remaining: m(7000000)},
+				{Type: RoomRRSP, Accounts: []string{"1"}, Unconverted: true},
+				{Type: RoomFHSA, Accounts: []string{}, Limit: m(8000000), Remaining: m(8000000)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rooms(tt.accounts, tt.limits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rooms() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// Package history is an append-only audit log of sync runs, stored as JSON lines so
+// records survive crashes midway through a write and can be inspected with standard tools.
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/jsonl"
+	"github.com/brymastr/questrade-ynab/internal/ynab"
+)
+
+// Run outcomes
+const (
+	OutcomeApplied        = "applied"
+	OutcomeNoChanges      = "no_changes"
+	OutcomeDryRun         = "dry_run"
+	OutcomeAborted        = "aborted"
+	OutcomeRefused        = "refused"
+	OutcomePartialFailure = "partial_failure"
+	OutcomeFailed         = "failed"
+	// OutcomeDeferred is a sync skipped by --after-close-only while markets were open
+	OutcomeDeferred = "deferred"
+)
+
+// Transaction kinds
+const (
+	KindSync           = "sync"
+	KindReconciliation = "reconciliation"
+	// KindReversal is an offsetting transaction posted by an undo
+	KindReversal = "reversal"
+	// KindDeleted records a transaction deleted by an undo
+	KindDeleted = "deleted"
+)
+
+// ErrNotFound is returned by Store.Get when no run matches
+var ErrNotFound = errors.New("history: run not found")
+
+// Run is the record of one sync run
+type Run struct {
+	ID         string    `json:"id" yaml:"id"`
+	StartedAt  time.Time `json:"started_at" yaml:"started_at"`
+	FinishedAt time.Time `json:"finished_at" yaml:"finished_at"`
+	BudgetID   string    `json:"budget_id" yaml:"budget_id"`
+	Currency   string    `json:"currency,omitempty" yaml:"currency,omitempty"`
+	DryRun     bool      `json:"dry_run" yaml:"dry_run"`
+	Reconcile  bool      `json:"reconcile,omitempty" yaml:"reconcile,omitempty"`
+	// Intraday is set when balances were fetched while TSX or NYSE was open
+	Intraday bool   `json:"intraday,omitempty" yaml:"intraday,omitempty"`
+	Outcome  string `json:"outcome" yaml:"outcome"`
+	// UndoOf is set on undo runs to the ID of the run being undone
+	UndoOf string `json:"undo_of,omitempty" yaml:"undo_of,omitempty"`
+
+	Accounts     []Account     `json:"accounts" yaml:"accounts"`
+	Planned      []Planned     `json:"planned" yaml:"planned"`
+	Transactions []Transaction `json:"transactions,omitempty" yaml:"transactions,omitempty"`
+	Errors       []string      `json:"errors,omitempty" yaml:"errors,omitempty"`
+	// Warnings are the issues balance checks found before applying
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// Account records the balances fetched for one mapped account pair
+type Account struct {
+	QuestradeNumber  string          `json:"questrade_number" yaml:"questrade_number"`
+	QuestradeType    string          `json:"questrade_type,omitempty" yaml:"questrade_type,omitempty"`
+	QuestradeBalance ynab.Milliunits `json:"questrade_balance_milliunits" yaml:"questrade_balance_milliunits"`
+	YNABAccountID    string          `json:"ynab_account_id" yaml:"ynab_account_id"`
+	YNABName         string          `json:"ynab_account" yaml:"ynab_account"`
+	YNABCleared      ynab.Milliunits `json:"ynab_cleared_milliunits" yaml:"ynab_cleared_milliunits"`
+	YNABUncleared    ynab.Milliunits `json:"ynab_uncleared_milliunits" yaml:"ynab_uncleared_milliunits"`
+}
+
+// Planned is an adjustment the run intended to make
+type Planned struct {
+	YNABAccountID string          `json:"ynab_account_id" yaml:"ynab_account_id"`
+	YNABName      string          `json:"ynab_account" yaml:"ynab_account"`
+	OldBalance    ynab.Milliunits `json:"old_balance_milliunits" yaml:"old_balance_milliunits"`
+	NewBalance    ynab.Milliunits `json:"new_balance_milliunits" yaml:"new_balance_milliunits"`
+	Amount        ynab.Milliunits `json:"amount_milliunits" yaml:"amount_milliunits"`
+}
+
+// Transaction is a YNAB transaction the run created, or tried to create
+type Transaction struct {
+	Kind          string          `json:"kind" yaml:"kind"`
+	YNABAccountID string          `json:"ynab_account_id" yaml:"ynab_account_id"`
+	YNABName      string          `json:"ynab_account" yaml:"ynab_account"`
+	Date          string          `json:"date" yaml:"date"`
+	Amount        ynab.Milliunits `json:"amount_milliunits" yaml:"amount_milliunits"`
+	TransactionID string          `json:"transaction_id,omitempty" yaml:"transaction_id,omitempty"`
+	// Reverts is the ID of the transaction an undo deleted or offset
+	Reverts string `json:"reverts,omitempty" yaml:"reverts,omitempty"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Created returns the transactions that were successfully created in YNAB
+func (r *Run) Created() []Transaction {
+	var created []Transaction
+	for _, tx := range r.Transactions {
+		if tx.TransactionID != "" {
+			created = append(created, tx)
+		}
+	}
+	return created
+}
+
+// Outstanding returns the transactions created by run that no undo run in runs has
+// deleted or offset yet
+func Outstanding(runs []Run, run *Run) []Transaction {
+	reverted := make(map[string]bool)
+	for _, r := range runs {
+		if r.UndoOf != run.ID {
+			continue
+		}
+		for _, tx := range r.Transactions {
+			if tx.Reverts != "" && tx.Error == "" {
+				reverted[tx.Reverts] = true
+			}
+		}
+	}
+	var outstanding []Transaction
+	for _, tx := range run.Created() {
+		if !reverted[tx.TransactionID] {
+			outstanding = append(outstanding, tx)
+		}
+	}
+	return outstanding
+}
+
+// LastSynced returns the start of the most recent run that brought the YNAB account in
+// line with Questrade: an applied or unchanged sync that fetched the account and, if it
+// planned a change for it, created the adjustment. runs are oldest first, as List
+// returns them.
+func LastSynced(runs []Run, ynabAccountID string) (time.Time, bool) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		if r.DryRun || r.UndoOf != "" {
+			continue
+		}
+		switch r.Outcome {
+		case OutcomeApplied, OutcomeNoChanges, OutcomePartialFailure:
+		default:
+			continue
+		}
+		fetched := false
+		for _, a := range r.Accounts {
+			if a.YNABAccountID == ynabAccountID {
+				fetched = true
+				break
+			}
+		}
+		if !fetched {
+			continue
+		}
+		planned, created := false, false
+		for _, p := range r.Planned {
+			if p.YNABAccountID == ynabAccountID {
+				planned = true
+			}
+		}
+		for _, tx := range r.Transactions {
+			if tx.YNABAccountID == ynabAccountID && tx.Kind == KindSync && tx.TransactionID != "" && tx.Error == "" {
+				created = true
+			}
+		}
+		if planned && !created {
+			continue
+		}
+		return r.StartedAt, true
+	}
+	return time.Time{}, false
+}
+
+// NewRunID returns a sortable, practically unique ID for a run started at t
+func NewRunID(t time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return t.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// Store is a history file. Runs are appended one JSON object per line.
+type Store struct {
+	lines *jsonl.File[Run]
+}
+
+// NewStore returns the store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{lines: jsonl.New[Run](path)}
+}
+
+// Path returns the history file location
+func (s *Store) Path() string {
+	return s.lines.Path()
+}
+
+// Append adds a run to the end of the history
+func (s *Store) Append(run Run) error {
+	return s.lines.Append(run)
+}
+
+// List returns all recorded runs, oldest first. A missing file is an empty history.
+// Lines that cannot be parsed, such as a torn final write, are skipped.
+func (s *Store) List() ([]Run, error) {
+	return s.lines.List()
+}
+
+// Get returns the run whose ID is id or starts with it. "last" selects the most recent run.
+func (s *Store) Get(id string) (*Run, error) {
+	runs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if id == "last" {
+		if len(runs) == 0 {
+			return nil, ErrNotFound
+		}
+		return &runs[len(runs)-1], nil
+	}
+	var match *Run
+	for i := range runs {
+		if runs[i].ID == id {
+			return &runs[i], nil
+		}
+		if strings.HasPrefix(runs[i].ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("run ID %q is ambiguous", id)
+			}
+			match = &runs[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrNotFound
+	}
+	return match, nil
+}
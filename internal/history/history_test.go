@@ -0,0 +1,182 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastSynced(t *testing.T) {
+	t1 := time.Date(2026, 3, 2, 21, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	fetched := []Account{{YNABAccountID: "acct"}}
+	planned := []Planned{{YNABAccountID: "acct"}}
+	created := []Transaction{{Kind: KindSync, YNABAccountID: "acct", TransactionID: "tx"}}
+
+	tests := []struct {
+		name   string
+		runs   []Run
+		want   time.Time
+		wantOK bool
+	}{
+		{"no runs", nil, time.Time{}, false},
+		{
+			name:   "unchanged sync",
+			runs:   []Run{{StartedAt: t1, Outcome: OutcomeNoChanges, Accounts: fetched}},
+			want:   t1,
+			wantOK: true,
+		},
+		{
+			name: "latest applied run wins",
+			runs: []Run{
+				{StartedAt: t1, Outcome: OutcomeNoChanges, Accounts: fetched},
+				{StartedAt: t2, Outcome: OutcomeApplied, Accounts: fetched, Planned: planned, Transactions: created},
+			},
+			want:   t2,
+			wantOK: true,
+		},
+		{
+			name: "failed adjustment is skipped",
+			runs: []Run{
+				{StartedAt: t1, Outcome: OutcomeNoChanges, Accounts: fetched},
+				{StartedAt: t2, Outcome: OutcomePartialFailure, Accounts: fetched, Planned: planned, Transactions: []Transaction{
+					{Kind: KindSync, YNABAccountID: "acct", Error: "500"},
+				}},
+			},
+			want:   t1,
+			wantOK: true,
+		},
+		{
+			name: "reconciliation alone does not count as the adjustment",
+			runs: []Run{{StartedAt: t2, Outcome: OutcomeApplied, Accounts: fetched, Planned: planned, Transactions: []Transaction{
+				{Kind: KindReconciliation, YNABAccountID: "acct", TransactionID: "rec"},
+			}}},
+			wantOK: false,
+		},
+		{
+			name: "dry runs, undos and other outcomes are ignored",
+			runs: []Run{
+				{StartedAt: t1, Outcome: OutcomeDryRun, DryRun: true, Accounts: fetched},
+				{StartedAt: t1, Outcome: OutcomeApplied, UndoOf: "run", Accounts: fetched},
+				{StartedAt: t1, Outcome: OutcomeAborted, Accounts: fetched},
+				{StartedAt: t1, Outcome: OutcomeRefused, Accounts: fetched},
+				{StartedAt: t1, Outcome: OutcomeFailed, Accounts: fetched},
+				{StartedAt: t1, Outcome: OutcomeDeferred},
+			},
+			wantOK: false,
+		},
+		{
+			name:   "other account",
+			runs:   []Run{{StartedAt: t1, Outcome: OutcomeApplied, Accounts: []Account{{YNABAccountID: "other"}}}},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LastSynced(tt.runs, "acct")
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("LastSynced() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOutstanding(t *testing.T) {
+	run := Run{ID: "run", Transactions: []Transaction{
+		{Kind: KindSync, YNABAccountID: "a", TransactionID: "tx1"},
+		{Kind: KindSync, YNABAccountID: "b", TransactionID: "tx2"},
+		{Kind: KindReconciliation, YNABAccountID: "b", TransactionID: "tx3"},
+		{Kind: KindSync, YNABAccountID: "c", Error: "500"},
+	}}
+
+	tests := []struct {
+		name string
+		undo []Run
+		want []string
+	}{
+		{"not undone", nil, []string{"tx1", "tx2", "tx3"}},
+		{
+			name: "deleted and offset",
+			undo: []Run{{UndoOf: "run", Transactions: []Transaction{
+				{Kind: KindDeleted, Reverts: "tx1"},
+				{Kind: KindReversal, Reverts: "tx3", TransactionID: "rev"},
+			}}},
+			want: []string{"tx2"},
+		},
+		{
+			name: "failed undo leaves the transaction",
+			undo: []Run{{UndoOf: "run", Transactions: []Transaction{{Kind: KindDeleted, Reverts: "tx1", Error: "500"}}}},
+			want: []string{"tx1", "tx2", "tx3"},
+		},
+		{
+			name: "undo of another run",
+			undo: []Run{{UndoOf: "other", Transactions: []Transaction{{Kind: KindDeleted, Reverts: "tx1"}}}},
+			want: []string{"tx1", "tx2", "tx3"},
+		},
+		{
+			name: "everything undone across runs",
+			undo: []Run{
+				{UndoOf: "run", Transactions: []Transaction{{Kind: KindDeleted, Reverts: "tx1"}}},
+				{UndoOf: "run", Transactions: []Transaction{{Kind: KindDeleted, Reverts: "tx2"}, {Kind: KindDeleted, Reverts: "tx3"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := append([]Run{run}, tt.undo...)
+			var got []string
+			for _, tx := range Outstanding(runs, &runs[0]) {
+				got = append(got, tx.TransactionID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Outstanding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreGet(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if _, err := store.Get("last"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(last) on an empty history: error = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"20260302T210000Z-ab12", "20260302T210000Z-ab34", "20260303T210000Z-cd56", "20260303T210000Z"} {
+		if err := store.Append(Run{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr string
+	}{
+		{id: "20260302T210000Z-ab34", want: "20260302T210000Z-ab34"},
+		{id: "20260302T210000Z-ab1", want: "20260302T210000Z-ab12"},
+		{id: "last", want: "20260303T210000Z"},
+		// An exact ID wins over the longer IDs it prefixes
+		{id: "20260303T210000Z", want: "20260303T210000Z"},
+		{id: "20260302T210000Z-ab", wantErr: "ambiguous"},
+		{id: "20260304", wantErr: ErrNotFound.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := store.Get(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.id, err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("Get(%q) = %s, want %s", tt.id, got.ID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package jsonl stores records in an append-only file, one JSON object per line.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File is a JSON lines file of records of type T
+type File[T any] struct {
+	path string
+}
+
+// New returns the file at path. It is created on the first Append.
+func New[T any](path string) *File[T] {
+	return &File[T]{path: path}
+}
+
+// Path returns the file location
+func (f *File[T]) Path() string {
+	return f.path
+}
+
+// Append adds a record to the end of the file and syncs it to disk
+func (f *File[T]) Append(v T) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	// A single write keeps the line intact even if another process appends concurrently
+	if _, err := fh.Write(append(line, '\n')); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// List returns all records in file order. A missing file has none. Lines that cannot be
+// parsed, such as a torn final write, are skipped.
+func (f *File[T]) List() ([]T, error) {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	var records []T
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			continue
+		}
+		records = append(records, v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,52 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type record struct {
+	ID    string `json:"id"`
+	Value int    `json:"value"`
+}
+
+func TestFileAppendList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "records.jsonl")
+	f := New[record](path)
+
+	got, err := f.List()
+	if err != nil || got != nil {
+		t.Fatalf("List() on a missing file = %v, %v; want nil, nil", got, err)
+	}
+
+	want := []record{{"a", 1}, {"b", 2}}
+	for _, r := range want {
+		if err := f.Append(r); err != nil {
+			t.Fatalf("Append(%v) = %v", r, err)
+		}
+	}
+	// A torn final write and blank lines are skipped
+	fh, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.WriteString("\n{\"id\":\"c\",\"val")
+	fh.Close()
+
+	got, err = f.List()
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", fi.Mode().Perm())
+	}
+}
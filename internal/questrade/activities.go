@@ -0,0 +1,77 @@
+package questrade
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Activity types reported by Questrade
+const (
+	ActivityDeposits    = "Deposits"
+	ActivityWithdrawals = "Withdrawals"
+	ActivityTransfers   = "Transfers"
+)
+
+// maxActivityRange is the longest period Questrade returns activities for in one request
+const maxActivityRange = 30 * 24 * time.Hour
+
+// Activity is an account activity such as a trade, deposit or dividend. Amounts are in
+// Currency; withdrawals have a negative NetAmount.
+type Activity struct {
+	TradeDate       time.Time `json:"tradeDate"`
+	TransactionDate time.Time `json:"transactionDate"`
+	SettlementDate  time.Time `json:"settlementDate"`
+	Action          string    `json:"action"`
+	Symbol          string    `json:"symbol"`
+	Description     string    `json:"description"`
+	Currency        string    `json:"currency"`
+	Quantity        float64   `json:"quantity"`
+	Price           float64   `json:"price"`
+	GrossAmount     float64   `json:"grossAmount"`
+	Commission      float64   `json:"commission"`
+	NetAmount       float64   `json:"netAmount"`
+	Type            string    `json:"type"`
+}
+
+// IsContribution reports whether the activity moved cash into or out of the account:
+// a deposit or a withdrawal
+func (a Activity) IsContribution() bool {
+	return a.Type == ActivityDeposits || a.Type == ActivityWithdrawals
+}
+
+// ActivitiesResponse is the /v1/accounts/:id/activities response
+type ActivitiesResponse struct {
+	Activities []Activity `json:"activities"`
+}
+
+// GetActivities returns the activities of an account between start and end
+func (c *Client) GetActivities(accountNumber string, start, end time.Time) ([]Activity, error) {
+	return c.GetActivitiesContext(context.Background(), accountNumber, start, end)
+}
+
+// GetActivitiesContext is like GetActivities but bound to ctx. Questrade limits each
+// request to about a month, so longer periods are fetched in consecutive windows.
+func (c *Client) GetActivitiesContext(ctx context.Context, accountNumber string, start, end time.Time) ([]Activity, error) {
+	var activities []Activity
+	for from := start; from.Before(end); from = from.Add(maxActivityRange) {
+		to := from.Add(maxActivityRange)
+		if to.After(end) {
+			to = end
+		}
+		// Both ends are inclusive; stop short of the next window's start
+		windowEnd := to
+		if to.Before(end) {
+			windowEnd = to.Add(-time.Second)
+		}
+		query := url.Values{}
+		query.Set("startTime", from.Format(time.RFC3339))
+		query.Set("endTime", windowEnd.Format(time.RFC3339))
+		var resp ActivitiesResponse
+		if err := c.getJSON(ctx, &resp, query, "v1", "accounts", accountNumber, "activities"); err != nil {
+			return nil, err
+		}
+		activities = append(activities, resp.Activities...)
+	}
+	return activities, nil
+}
@@ -1,10 +1,11 @@
 package questrade
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,12 +15,18 @@ import (
 
 const productionAuthURL = "https://login.questrade.com/oauth2/token"
 
+// RefreshTokenLifetime is how long a refresh token stays valid without being used.
+// Every Refresh issues a new refresh token, restarting the window.
+const RefreshTokenLifetime = 7 * 24 * time.Hour
+
 type Client struct {
-	refreshToken string
-	accessToken  string
-	apiServer    string
-	httpClient   *http.Client
-	expiresAt    time.Time
+	refreshToken   string
+	accessToken    string
+	apiServer      string
+	httpClient     *http.Client
+	expiresAt      time.Time
+	limiter        rateLimiter
+	maxConcurrency int
 }
 
 type TokenResponse struct {
@@ -38,6 +45,8 @@ type Account struct {
 	IsBilling         bool             `json:"isBilling"`
 	ClientAccountType string           `json:"clientAccountType"`
 	Balances          *AccountBalances `json:"-"` // Populated by GetAccounts in parallel
+	// BalancesErr is why Balances could not be fetched, when GetAccounts left it nil
+	BalancesErr error `json:"-"`
 }
 
 type Balance struct {
@@ -69,23 +78,45 @@ type AccountsResponse struct {
 
 func NewClient(refreshToken string) *Client {
 	return &Client{
-		refreshToken: refreshToken,
-		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		refreshToken:   refreshToken,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		maxConcurrency: defaultMaxConcurrency,
+	}
+}
+
+// SetMaxConcurrency bounds how many requests fan-out calls such as GetAccounts run in parallel
+func (c *Client) SetMaxConcurrency(n int) {
+	if n < 1 {
+		n = 1
 	}
+	c.maxConcurrency = n
+}
+
+// SetHTTPClient replaces the underlying HTTP client, e.g. to change the default 10s timeout.
+// Per-request deadlines are better expressed through the context passed to the *Context methods.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
 }
 
 // Refresh exchanges the stored refresh token for a short-lived access token and API server
 // It returns the parsed token response so callers may persist values as needed.
 func (c *Client) Refresh() (*TokenResponse, error) {
+	return c.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh but bound to ctx
+func (c *Client) RefreshContext(ctx context.Context) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", c.refreshToken)
 
-	// Build full URL (for logging) and log the request we're about to send so we can debug endpoint issues
-	fullURL := productionAuthURL + "?" + data.Encode()
-	log.Printf("questrade token refresh request: POST %s body=%s", fullURL, data.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", productionAuthURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.httpClient.PostForm(productionAuthURL, data)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
@@ -96,22 +127,23 @@ func (c *Client) Refresh() (*TokenResponse, error) {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Always log the response body and status so we can inspect successful replies too
-	log.Printf("questrade token refresh response: status=%d body=%s", resp.StatusCode, string(body))
-
-	// Log the raw response body and status for debugging endpoint issues
+	// The request and response bodies carry the refresh and access tokens, so neither is
+	// logged or included in errors except for failed responses, which carry none
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token refresh failed: status %d", resp.StatusCode)
+		apiErr := newAPIError(resp, body)
+		// Questrade answers 400 for a spent, revoked or mistyped refresh token
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("token refresh failed: %w: %w", ErrRefreshRejected, apiErr)
+		}
+		return nil, fmt.Errorf("token refresh failed: %w", apiErr)
 	}
 
 	var tokenResp TokenResponse
 	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		log.Printf("failed to parse token response body: %s", string(body))
 		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
 	if tokenResp.AccessToken == "" {
-		log.Printf("token response missing access_token: %s", string(body))
 		return nil, fmt.Errorf("no access token in response")
 	}
 
@@ -146,11 +178,11 @@ func (c *Client) GetExpiresAt() time.Time {
 	return c.expiresAt
 }
 
-// SetAccessToken sets the access token, api server and expiration directly (used when loading cached token)
-func (c *Client) SetAccessToken(accessToken, apiServer string, expiresIn int) {
+// SetAccessToken sets the access token, api server and absolute expiration directly (used when loading cached token)
+func (c *Client) SetAccessToken(accessToken, apiServer string, expiresAt time.Time) {
 	c.accessToken = accessToken
 	c.apiServer = apiServer
-	c.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	c.expiresAt = expiresAt
 }
 
 // IsTokenValid returns true if the current access token is still valid
@@ -163,79 +195,45 @@ func (c *Client) IsTokenValid() bool {
 
 // IsAccessTokenValid performs a live check against the Questrade API using the provided
 // access token and apiServer. It calls the /v1/time endpoint. If the endpoint returns
-// 200 the token is valid. If it returns 401 or a body indicating an invalid token the
-// token is considered invalid. Any other non-200 status returns an error.
+// 200 the token is valid. If it returns an error matching ErrUnauthorized the token is
+// considered invalid. Any other non-200 status returns an *APIError.
 func (c *Client) IsAccessTokenValid(accessToken, apiServer string) (bool, error) {
+	return c.IsAccessTokenValidContext(context.Background(), accessToken, apiServer)
+}
+
+// IsAccessTokenValidContext is like IsAccessTokenValid but bound to ctx
+func (c *Client) IsAccessTokenValidContext(ctx context.Context, accessToken, apiServer string) (bool, error) {
 	if accessToken == "" || apiServer == "" {
 		return false, nil
 	}
 
-	// Ensure apiServer does not have a trailing slash duplication
-	urlStr := fmt.Sprintf("%s/v1/time", strings.TrimRight(apiServer, "/"))
-	req, err := http.NewRequest("GET", urlStr, nil)
+	req, err := newAPIRequest(ctx, "GET", apiServer, accessToken, nil, "v1", "time")
 	if err != nil {
-		return false, fmt.Errorf("failed to create request: %w", err)
+		return false, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
+	err = c.doJSON(req, nil)
+	if err == nil {
 		return true, nil
 	}
-	// 401 indicates invalid token
-	if resp.StatusCode == http.StatusUnauthorized {
-		return false, nil
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	lower := strings.ToLower(string(body))
-	if strings.Contains(lower, "invalid") && strings.Contains(lower, "access") {
+	// 401 or error code 1017 indicates an invalid token
+	if errors.Is(err, ErrUnauthorized) {
 		return false, nil
 	}
-
-	return false, fmt.Errorf("unexpected status from token validate: %d - %s", resp.StatusCode, string(body))
+	return false, err
 }
 
 // GetAccountBalances retrieves balance information for an account
 func (c *Client) GetAccountBalances(accountNumber string) (*Balance, error) {
-	if c.accessToken == "" {
-		return nil, fmt.Errorf("not authenticated, call Refresh first")
-	}
-
-	url := fmt.Sprintf("%s/v1/accounts/%s/balances", c.apiServer, accountNumber)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	return c.GetAccountBalancesContext(context.Background(), accountNumber)
+}
 
+// GetAccountBalancesContext is like GetAccountBalances but bound to ctx
+func (c *Client) GetAccountBalancesContext(ctx context.Context, accountNumber string) (*Balance, error) {
 	var balanceResp struct {
 		CombinedBalances []Balance `json:"combinedBalances"`
 	}
-	if err := json.Unmarshal(body, &balanceResp); err != nil {
-		return nil, fmt.Errorf("failed to parse balance response: %w", err)
+	if err := c.getJSON(ctx, &balanceResp, nil, "v1", "accounts", accountNumber, "balances"); err != nil {
+		return nil, err
 	}
 
 	if len(balanceResp.CombinedBalances) > 0 {
@@ -248,91 +246,59 @@ func (c *Client) GetAccountBalances(accountNumber string) (*Balance, error) {
 // GetAccountBalancesByID retrieves detailed balance information for an account by account ID.
 // Returns per-currency and combined balances from the /v1/accounts/{id}/balances endpoint.
 func (c *Client) GetAccountBalancesByID(accountID string) (*AccountBalances, error) {
-	if c.accessToken == "" {
-		return nil, fmt.Errorf("not authenticated, call Refresh first")
-	}
-
-	url := fmt.Sprintf("%sv1/accounts/%s/balances", c.apiServer, accountID)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	return c.GetAccountBalancesByIDContext(context.Background(), accountID)
+}
 
+// GetAccountBalancesByIDContext is like GetAccountBalancesByID but bound to ctx
+func (c *Client) GetAccountBalancesByIDContext(ctx context.Context, accountID string) (*AccountBalances, error) {
 	var balances AccountBalances
-	if err := json.Unmarshal(body, &balances); err != nil {
-		return nil, fmt.Errorf("failed to parse balance response: %w", err)
+	if err := c.getJSON(ctx, &balances, nil, "v1", "accounts", accountID, "balances"); err != nil {
+		return nil, err
 	}
-
 	return &balances, nil
 }
 
 // GetAccounts retrieves all accounts
 func (c *Client) GetAccounts() ([]Account, error) {
-	if c.accessToken == "" {
-		return nil, fmt.Errorf("not authenticated, call Refresh first")
-	}
-
-	url := fmt.Sprintf("%sv1/accounts", c.apiServer)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	return c.GetAccountsContext(context.Background())
+}
 
+// GetAccountsContext retrieves all accounts and their balances. Balances are fetched in
+// parallel. An account whose balances cannot be fetched is still returned, with Balances
+// nil and the error in BalancesErr, so one broken account does not hide the others; only
+// listing the accounts or cancellation fails the call.
+func (c *Client) GetAccountsContext(ctx context.Context) ([]Account, error) {
 	var accountsResp AccountsResponse
-	if err := json.Unmarshal(body, &accountsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse accounts response: %w", err)
+	if err := c.getJSON(ctx, &accountsResp, nil, "v1", "accounts"); err != nil {
+		return nil, err
 	}
 
-	// Fetch balances for each account in parallel
+	// Fetch balances for each account with a bounded worker pool
 	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.maxConcurrency)
 	wg.Add(len(accountsResp.Accounts))
 	for i := range accountsResp.Accounts {
-		go func(idx int) {
+		go func(acc *Account) {
 			defer wg.Done()
-			balances, err := c.GetAccountBalancesByID(accountsResp.Accounts[idx].Number)
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				acc.BalancesErr = ctx.Err()
+				return
+			}
+			balances, err := c.GetAccountBalancesByIDContext(ctx, acc.Number)
 			if err != nil {
-				log.Printf("Warning: failed to fetch balances for account %s: %v", accountsResp.Accounts[idx].Number, err)
+				acc.BalancesErr = fmt.Errorf("failed to fetch balances for account %s: %w", acc.Number, err)
 				return
 			}
-			accountsResp.Accounts[idx].Balances = balances
-		}(i)
+			acc.Balances = balances
+		}(&accountsResp.Accounts[i])
 	}
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return accountsResp.Accounts, nil
 }
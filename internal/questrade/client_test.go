@@ -0,0 +1,76 @@
+package questrade
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc serves requests without a network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRefreshErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		transportErr error
+		wantRejected bool
+	}{
+		{name: "spent token", status: http.StatusBadRequest, body: "Bad Request", wantRejected: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"code":1017,"message":"Access token is invalid"}`, wantRejected: true},
+		{name: "server error", status: http.StatusBadGateway, body: "Bad Gateway"},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"code":1006,"message":"Rate limit exceeded"}`},
+		{name: "network failure", transportErr: errors.New("dial tcp: lookup login.questrade.com: no such host")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("refresh")
+			c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if tt.transportErr != nil {
+					return nil, tt.transportErr
+				}
+				return &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}, nil
+			})})
+			_, err := c.RefreshContext(context.Background())
+			if err == nil {
+				t.Fatal("RefreshContext() succeeded, want an error")
+			}
+			if got := errors.Is(err, ErrRefreshRejected); got != tt.wantRejected {
+				t.Errorf("errors.Is(%v, ErrRefreshRejected) = %v, want %v", err, got, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	c := NewClient("old")
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"access_token":"access","api_server":"https://api01.iq.questrade.com/","expires_in":1800,"refresh_token":"new","token_type":"Bearer"}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})})
+	tr, err := c.RefreshContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.AccessToken != "access" || c.GetRefreshToken() != "new" || c.GetAPIServer() != "https://api01.iq.questrade.com/" {
+		t.Errorf("RefreshContext() = %+v, refresh token %q, api server %q", tr, c.GetRefreshToken(), c.GetAPIServer())
+	}
+	if logged.Len() > 0 {
+		t.Errorf("RefreshContext() logged %q; tokens must not be logged", logged.String())
+	}
+}
@@ -0,0 +1,79 @@
+package questrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sentinel errors classifying API failures. Test with errors.Is; use errors.As with
+// *APIError for the status code, Questrade error code and rate limit reset time.
+var (
+	ErrUnauthorized = errors.New("questrade: unauthorized")
+	ErrNotFound     = errors.New("questrade: not found")
+	ErrRateLimited  = errors.New("questrade: rate limited")
+	// ErrRefreshRejected is returned by Refresh when the token endpoint rejected the
+	// refresh token itself, as opposed to a network or server failure
+	ErrRefreshRejected = errors.New("questrade: refresh token rejected")
+)
+
+// Questrade error codes returned in the JSON error body
+const (
+	codeRateLimitExceeded  = 1006
+	codeAccessTokenInvalid = 1017
+)
+
+// APIError is returned for any non-successful Questrade API response
+type APIError struct {
+	StatusCode int
+	// Code and Message come from the Questrade error body, e.g. 1017 "Access token is invalid"
+	Code    int
+	Message string
+	// Reset is when the rate limit window resets; set when the response carried the header
+	Reset time.Time
+}
+
+func (e *APIError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("questrade API error %d (code %d): %s", e.StatusCode, e.Code, e.Message)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("questrade API error %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("questrade API error %d", e.StatusCode)
+}
+
+// Is maps the error onto the package sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.Code == codeAccessTokenInvalid
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests || e.Code == codeRateLimitExceeded
+	}
+	return false
+}
+
+// newAPIError builds an APIError from a failed response and its already-read body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var errBody struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &errBody); err == nil && (errBody.Code != 0 || errBody.Message != "") {
+		apiErr.Code = errBody.Code
+		apiErr.Message = errBody.Message
+	} else {
+		apiErr.Message = string(body)
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		apiErr.Reset = time.Unix(reset, 0)
+	}
+	return apiErr
+}
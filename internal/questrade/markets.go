@@ -0,0 +1,90 @@
+package questrade
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Market is a trading venue and its session times for the current trading day
+type Market struct {
+	Name              string    `json:"name"`
+	Currency          string    `json:"currency"`
+	StartTime         time.Time `json:"startTime"`
+	EndTime           time.Time `json:"endTime"`
+	ExtendedStartTime time.Time `json:"extendedStartTime"`
+	ExtendedEndTime   time.Time `json:"extendedEndTime"`
+}
+
+// MarketsResponse is the /v1/markets response
+type MarketsResponse struct {
+	Markets []Market `json:"markets"`
+}
+
+// GetTime returns the current time according to Questrade
+func (c *Client) GetTime() (time.Time, error) {
+	return c.GetTimeContext(context.Background())
+}
+
+// GetTimeContext is like GetTime but bound to ctx
+func (c *Client) GetTimeContext(ctx context.Context) (time.Time, error) {
+	var resp struct {
+		Time time.Time `json:"time"`
+	}
+	if err := c.getJSON(ctx, &resp, nil, "v1", "time"); err != nil {
+		return time.Time{}, err
+	}
+	return resp.Time, nil
+}
+
+// GetMarkets returns the markets Questrade supports with today's session times
+func (c *Client) GetMarkets() ([]Market, error) {
+	return c.GetMarketsContext(context.Background())
+}
+
+// GetMarketsContext is like GetMarkets but bound to ctx
+func (c *Client) GetMarketsContext(ctx context.Context) ([]Market, error) {
+	var resp MarketsResponse
+	if err := c.getJSON(ctx, &resp, nil, "v1", "markets"); err != nil {
+		return nil, err
+	}
+	return resp.Markets, nil
+}
+
+// FindMarket returns the market with the given name (case-insensitive), or nil
+func FindMarket(markets []Market, name string) *Market {
+	for i := range markets {
+		if strings.EqualFold(markets[i].Name, name) {
+			return &markets[i]
+		}
+	}
+	return nil
+}
+
+// IsOpen reports whether the regular session is in progress at now
+func (m Market) IsOpen(now time.Time) bool {
+	return !now.Before(m.StartTime) && now.Before(m.EndTime)
+}
+
+// LastTradingDay returns the date, in the market's time zone, of the most recent regular
+// session that has closed as of now. Session times are for the market's current or next
+// trading day; earlier days are assumed to be weekdays, as Questrade does not publish
+// holiday calendars.
+func (m Market) LastTradingDay(now time.Time) time.Time {
+	session := dateOf(m.EndTime)
+	if !now.Before(m.EndTime) {
+		// Today's (or an earlier) session has closed
+		return session
+	}
+	// The session is today and not closed yet, or is a later day: the weekday before it
+	day := session.AddDate(0, 0, -1)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
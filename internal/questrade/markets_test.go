@@ -0,0 +1,71 @@
+package questrade
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketSession(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// tsx returns the TSX session on the given day, as Questrade reports it with the
+	// offset in effect that day
+	tsx := func(month time.Month, day int) Market {
+		start := time.Date(2026, month, day, 9, 30, 0, 0, toronto)
+		end := time.Date(2026, month, day, 16, 0, 0, 0, toronto)
+		_, offset := end.Zone()
+		zone := time.FixedZone("", offset)
+		return Market{Name: "TSX", StartTime: start.In(zone), EndTime: end.In(zone)}
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, toronto)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		market   Market
+		now      time.Time
+		wantOpen bool
+		wantDay  string
+	}{
+		{"after the close", tsx(time.March, 10), at(time.March, 10, 17, 0), false, "2026-03-10"},
+		{"at the close", tsx(time.March, 10), at(time.March, 10, 16, 0), false, "2026-03-10"},
+		{"during the session", tsx(time.March, 10), at(time.March, 10, 12, 0), true, "2026-03-09"},
+		{"at the open", tsx(time.March, 10), at(time.March, 10, 9, 30), true, "2026-03-09"},
+		{"before the open", tsx(time.March, 10), at(time.March, 10, 8, 0), false, "2026-03-09"},
+		{"Monday before the open", tsx(time.March, 16), at(time.March, 16, 8, 0), false, "2026-03-13"},
+		{"Saturday", tsx(time.March, 16), at(time.March, 14, 12, 0), false, "2026-03-13"},
+		// Clocks spring forward on Sunday 8 March; Monday's session is in EDT
+		{"Sunday of the spring DST change", tsx(time.March, 9), at(time.March, 8, 12, 0), false, "2026-03-06"},
+		{"first EDT close, in UTC", tsx(time.March, 9), utc(time.March, 9, 20, 30), false, "2026-03-09"},
+		{"an hour before the EDT close, in UTC", tsx(time.March, 9), utc(time.March, 9, 19, 30), true, "2026-03-06"},
+		// Clocks fall back on Sunday 1 November; Monday's session is in EST
+		{"last EST hour of the session, in UTC", tsx(time.November, 2), utc(time.November, 2, 20, 30), true, "2026-10-30"},
+		{"first EST close, in UTC", tsx(time.November, 2), utc(time.November, 2, 21, 0), false, "2026-11-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.market.IsOpen(tt.now); got != tt.wantOpen {
+				t.Errorf("IsOpen(%v) = %v, want %v", tt.now, got, tt.wantOpen)
+			}
+			if got := tt.market.LastTradingDay(tt.now).Format("2006-01-02"); got != tt.wantDay {
+				t.Errorf("LastTradingDay(%v) = %s, want %s", tt.now, got, tt.wantDay)
+			}
+		})
+	}
+}
+
+func TestFindMarket(t *testing.T) {
+	markets := []Market{{Name: "TSX"}, {Name: "NYSE"}}
+	if m := FindMarket(markets, "nyse"); m == nil || m.Name != "NYSE" {
+		t.Errorf("FindMarket(nyse) = %+v, want NYSE", m)
+	}
+	if m := FindMarket(markets, "LSE"); m != nil {
+		t.Errorf("FindMarket(LSE) = %+v, want nil", m)
+	}
+}
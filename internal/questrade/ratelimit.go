@@ -0,0 +1,153 @@
+package questrade
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxConcurrency bounds parallel requests such as the per-account balance fan-out
+	defaultMaxConcurrency = 4
+	// maxRetries is how many times a rate-limited or 5xx response is retried
+	maxRetries  = 4
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// rateLimiter tracks the X-RateLimit-Remaining and X-RateLimit-Reset headers Questrade
+// returns on every API response and holds requests back once the budget is spent.
+type rateLimiter struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// wait blocks until a request may be sent, reserving one unit of the remaining budget.
+// Like do, it does not wait longer than maxBackoff for the window to reset; a spent
+// hourly budget is reported as a rate-limited *APIError instead.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if !l.known || l.remaining > 0 || !now.Before(l.reset) {
+			if l.known && l.remaining > 0 {
+				l.remaining--
+			}
+			if l.known && !now.Before(l.reset) {
+				// Window rolled over; the next response will report the new budget
+				l.known = false
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		delay := l.reset.Sub(now)
+		reset := l.reset
+		l.mu.Unlock()
+		if delay > maxBackoff {
+			return &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Code:       codeRateLimitExceeded,
+				Message:    "request budget spent until " + reset.Local().Format(time.Kitchen),
+				Reset:      reset,
+			}
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// update records the budget reported by a response
+func (l *rateLimiter) update(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = true
+	l.remaining = remaining
+	l.reset = time.Unix(reset, 0)
+}
+
+// retryDelay returns how long to wait before retry number attempt (0-based). Rate-limited
+// responses wait for the advertised reset; everything else backs off exponentially with jitter.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if d := time.Until(time.Unix(reset, 0)); d > 0 {
+				return d
+			}
+		}
+	}
+	d := baseBackoff << attempt
+	if d > maxBackoff/2 {
+		d = maxBackoff / 2
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func retryable(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// do sends an API request, honouring the rate limit and retrying 429 and 5xx responses
+// and transport errors with backoff. The final response is returned as-is for the caller
+// to interpret.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := c.httpClient.Do(r)
+		if err != nil && (ctx.Err() != nil || attempt == maxRetries) {
+			return nil, err
+		}
+		delay := retryDelay(resp, attempt)
+		if err == nil {
+			c.limiter.update(resp.Header)
+			// An exhausted hourly budget is not worth waiting for; surface the 429 instead
+			if !retryable(resp) || attempt == maxRetries || delay > maxBackoff {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
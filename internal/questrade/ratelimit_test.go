@@ -0,0 +1,61 @@
+package questrade
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		known     bool
+		remaining int
+		resetIn   time.Duration
+		wantErr   bool
+		wantLeft  int
+	}{
+		{name: "unknown budget"},
+		{name: "budget left", known: true, remaining: 3, resetIn: time.Hour, wantLeft: 2},
+		{name: "window rolled over", known: true, resetIn: -time.Second},
+		{name: "short wait", known: true, resetIn: 10 * time.Millisecond},
+		{name: "spent for an hour", known: true, resetIn: time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &rateLimiter{known: tt.known, remaining: tt.remaining, reset: time.Now().Add(tt.resetIn)}
+			start := time.Now()
+			err := l.wait(context.Background())
+			if time.Since(start) > time.Second {
+				t.Fatalf("wait blocked for %s", time.Since(start))
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("wait() = %v, want nil", err)
+				}
+				if l.remaining != tt.wantLeft {
+					t.Errorf("remaining = %d, want %d", l.remaining, tt.wantLeft)
+				}
+				return
+			}
+			if !errors.Is(err, ErrRateLimited) {
+				t.Fatalf("wait() = %v, want ErrRateLimited", err)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Reset.IsZero() || apiErr.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("wait() = %#v, want an *APIError with Reset set", err)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	l := rateLimiter{known: true, remaining: 0, reset: time.Now().Add(maxBackoff / 2)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() = %v, want context.Canceled", err)
+	}
+}
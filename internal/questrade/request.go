@@ -0,0 +1,78 @@
+package questrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// endpointURL joins an API path such as "v1/accounts/123/balances" onto apiServer. Path
+// segments are joined with net/url so the result is the same whether or not apiServer
+// ends in "/".
+func endpointURL(apiServer string, query url.Values, path ...string) (string, error) {
+	base, err := url.Parse(apiServer)
+	if err != nil {
+		return "", fmt.Errorf("invalid api server %q: %w", apiServer, err)
+	}
+	u := base.JoinPath(path...)
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+// newAPIRequest builds a request against apiServer authenticated with accessToken
+func newAPIRequest(ctx context.Context, method, apiServer, accessToken string, query url.Values, path ...string) (*http.Request, error) {
+	u, err := endpointURL(apiServer, query, path...)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// getJSON issues an authenticated GET for the given path segments (e.g. "v1", "accounts")
+// and decodes a successful response into v. Failures are returned as *APIError.
+func (c *Client) getJSON(ctx context.Context, v interface{}, query url.Values, path ...string) error {
+	if c.accessToken == "" {
+		return fmt.Errorf("not authenticated, call Refresh first")
+	}
+	req, err := newAPIRequest(ctx, "GET", c.apiServer, c.accessToken, query, path...)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, v)
+}
+
+// doJSON sends req through the rate-limited transport and decodes a 200 response into v.
+// A nil v discards the body.
+func (c *Client) doJSON(req *http.Request, v interface{}) error {
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, body)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
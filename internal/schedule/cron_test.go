@@ -0,0 +1,146 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "expected 5 fields"},
+		{"* * * *", "expected 5 fields"},
+		{"* * * * * *", "expected 5 fields"},
+		{"60 * * * *", "minute field"},
+		{"* 24 * * *", "hour field"},
+		{"* * 0 * *", "day of month field"},
+		{"* * * 13 *", "month field"},
+		{"* * * * 8", "day of week field"},
+		{"5-1 * * * *", "invalid range"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"* * * FOO *", "month field"},
+		{"@fortnightly", "expected 5 fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec, time.UTC)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) = %v, want an error containing %q", tt.spec, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2026-01-05 is a Monday
+	from := time.Date(2026, 1, 5, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []string
+	}{
+		{"every minute", "* * * * *", from, []string{"2026-01-05 10:08", "2026-01-05 10:09"}},
+		{"strictly after a match", "8 10 * * *", time.Date(2026, 1, 5, 10, 8, 0, 0, time.UTC), []string{"2026-01-06 10:08"}},
+		{"minute step", "*/15 * * * *", from, []string{"2026-01-05 10:15", "2026-01-05 10:30", "2026-01-05 10:45", "2026-01-05 11:00"}},
+		{"stepped range", "0 9-17/4 * * *", from, []string{"2026-01-05 13:00", "2026-01-05 17:00", "2026-01-06 09:00"}},
+		{"step from a value", "5/20 * * * *", from, []string{"2026-01-05 10:25", "2026-01-05 10:45", "2026-01-05 11:05"}},
+		{"list", "0 8,12 * * *", from, []string{"2026-01-05 12:00", "2026-01-06 08:00"}},
+		{"weekday names", "30 16 * * MON-FRI", time.Date(2026, 1, 9, 17, 0, 0, 0, time.UTC), []string{"2026-01-12 16:30"}},
+		{"sunday as 7", "0 0 * * 7", from, []string{"2026-01-11 00:00", "2026-01-18 00:00"}},
+		{"sunday as 0", "0 0 * * sun", from, []string{"2026-01-11 00:00"}},
+		{"month names", "0 0 1 jul,DEC *", from, []string{"2026-07-01 00:00", "2026-12-01 00:00", "2027-07-01 00:00"}},
+		// Both day fields restricted: the 15th or any Friday
+		{"day of month or day of week", "0 0 15 * 5", from, []string{"2026-01-09 00:00", "2026-01-15 00:00", "2026-01-16 00:00", "2026-01-23 00:00"}},
+		// Only one restricted: both must match, and * in the other matches every day
+		{"day of month only", "0 0 15 * *", from, []string{"2026-01-15 00:00", "2026-02-15 00:00"}},
+		{"day of week with question mark", "0 0 ? * 5", from, []string{"2026-01-09 00:00", "2026-01-16 00:00"}},
+		{"31st skips short months", "0 0 31 * *", from, []string{"2026-01-31 00:00", "2026-03-31 00:00", "2026-05-31 00:00"}},
+		{"leap day", "0 0 29 2 *", from, []string{"2028-02-29 00:00", "2032-02-29 00:00"}},
+		{"year rollover", "0 0 1 1 *", from, []string{"2027-01-01 00:00"}},
+		{"hourly descriptor", "@hourly", from, []string{"2026-01-05 11:00", "2026-01-05 12:00"}},
+		{"weekly descriptor", "@WEEKLY", from, []string{"2026-01-11 00:00"}},
+		{"never fires", "0 0 30 2 *", from, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, time.UTC)
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", tt.spec, err)
+			}
+			next := tt.from
+			if tt.want == nil {
+				if got := s.Next(next); !got.IsZero() {
+					t.Errorf("Next() = %v, want zero", got)
+				}
+				return
+			}
+			for i, want := range tt.want {
+				next = s.Next(next)
+				if got := next.Format("2006-01-02 15:04"); got != want {
+					t.Fatalf("Next() #%d = %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextTimeZones(t *testing.T) {
+	toronto := mustLoad(t, "America/Toronto")
+	stJohns := mustLoad(t, "America/St_Johns")
+	tests := []struct {
+		name string
+		spec string
+		loc  *time.Location
+		from time.Time
+		// want are successive results formatted with their UTC offset
+		want []string
+	}{
+		{"evaluated in the schedule's zone", "0 18 * * *", toronto, time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC),
+			[]string{"2026-01-05 18:00 -0500"}},
+		// Clocks jump from 2:00 to 3:00 on 2026-03-08; 2:30 fires past the jump
+		{"spring forward skipped time", "30 2 * * *", toronto, time.Date(2026, 3, 7, 12, 0, 0, 0, toronto),
+			[]string{"2026-03-08 03:30 -0400", "2026-03-09 02:30 -0400"}},
+		// 2:00 and 3:00 land on the same instant and fire once
+		{"spring forward hourly", "0 * * * *", toronto, time.Date(2026, 3, 8, 0, 30, 0, 0, toronto),
+			[]string{"2026-03-08 01:00 -0500", "2026-03-08 03:00 -0400", "2026-03-08 04:00 -0400"}},
+		// Clocks fall back from 2:00 to 1:00 on 2026-11-01; 1:30 fires once
+		{"fall back repeated time", "30 1 * * *", toronto, time.Date(2026, 10, 31, 12, 0, 0, 0, toronto),
+			[]string{"2026-11-01 01:30 -0400", "2026-11-02 01:30 -0500"}},
+		{"fall back hourly", "0 * * * *", toronto, time.Date(2026, 11, 1, 0, 30, 0, 0, toronto),
+			[]string{"2026-11-01 01:00 -0400", "2026-11-01 02:00 -0500", "2026-11-01 03:00 -0500"}},
+		{"half-hour offset", "0 * * * *", stJohns, time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC),
+			[]string{"2026-01-05 09:00 -0330", "2026-01-05 10:00 -0330"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.loc)
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", tt.spec, err)
+			}
+			next := tt.from
+			for i, want := range tt.want {
+				next = s.Next(next)
+				if got := next.Format("2006-01-02 15:04 -0700"); got != want {
+					t.Fatalf("Next() #%d = %s, want %s", i+1, got, want)
+				}
+				if next.Location() != tt.loc {
+					t.Errorf("Next() #%d is in %v, want %v", i+1, next.Location(), tt.loc)
+				}
+			}
+		})
+	}
+}
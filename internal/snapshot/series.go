@@ -0,0 +1,166 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Grouping dimensions for Aggregate
+const (
+	ByAccount  = "account"
+	ByType     = "type"
+	ByCurrency = "currency"
+)
+
+// Bucket periods for Aggregate
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Series is a time series of balances, one column per group. Values[i][j] is the value
+// of Groups[j] at Times[i]; a nil value means the group had no balance in that bucket.
+type Series struct {
+	By     string       `json:"by" yaml:"by"`
+	Period string       `json:"period" yaml:"period"`
+	Groups []string     `json:"groups" yaml:"groups"`
+	Times  []time.Time  `json:"times" yaml:"times"`
+	Values [][]*float64 `json:"values" yaml:"values"`
+	// Currency is the currency of all values, empty when grouping by currency, where each
+	// group is in its own currency
+	Currency string `json:"currency,omitempty" yaml:"currency,omitempty"`
+	// Incomplete is set at times where an account's balance was missing and no earlier
+	// balance could stand in for it; nil when every bucket is complete
+	Incomplete []bool `json:"incomplete,omitempty" yaml:"incomplete,omitempty"`
+}
+
+// bucketStart truncates t to the start of its period in t's location
+func bucketStart(t time.Time, period string) time.Time {
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	switch period {
+	case PeriodWeek:
+		// Weeks start on Monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case PeriodMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+// Aggregate turns snapshots into a series grouped by account, account type or currency.
+// Within each period bucket the latest snapshot wins, so a bucket reflects the balances
+// at the end of the period. Unvalidated snapshots are skipped. An account a snapshot
+// lists as missing keeps its last known balance. Snapshots must be sorted oldest first.
+func Aggregate(snaps []Snapshot, by, period string, loc *time.Location) (*Series, error) {
+	switch by {
+	case ByAccount, ByType, ByCurrency:
+	default:
+		return nil, fmt.Errorf("invalid grouping %q (expected account, type or currency)", by)
+	}
+	switch period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+	default:
+		return nil, fmt.Errorf("invalid period %q (expected day, week or month)", period)
+	}
+
+	series := &Series{By: by, Period: period}
+	// latest holds the accounts of the latest snapshot in each bucket, with missing
+	// balances filled in from known, the last balance seen of each account
+	latest := make(map[time.Time][]Account)
+	incomplete := make(map[time.Time]bool)
+	known := make(map[string]Account)
+	for _, snap := range snaps {
+		if snap.Unvalidated {
+			continue
+		}
+		b := bucketStart(snap.TakenAt.In(loc), period)
+		if _, seen := latest[b]; !seen {
+			series.Times = append(series.Times, b)
+		}
+		accounts := append([]Account(nil), snap.Accounts...)
+		for _, acc := range snap.Accounts {
+			known[acc.Number] = acc
+		}
+		complete := true
+		for _, m := range snap.Missing {
+			if acc, ok := known[m.Number]; ok {
+				accounts = append(accounts, acc)
+			} else {
+				complete = false
+			}
+		}
+		latest[b] = accounts
+		incomplete[b] = !complete
+	}
+
+	groupSet := make(map[string]bool)
+	currencies := make(map[string]bool)
+	rows := make([]map[string]float64, len(series.Times))
+	for i, b := range series.Times {
+		if incomplete[b] {
+			if series.Incomplete == nil {
+				series.Incomplete = make([]bool, len(series.Times))
+			}
+			series.Incomplete[i] = true
+		}
+		rows[i] = make(map[string]float64)
+		for _, acc := range latest[b] {
+			switch by {
+			case ByAccount:
+				rows[i][fmt.Sprintf("%s (%s)", acc.Number, acc.Type)] += acc.TotalEquity
+			case ByType:
+				rows[i][acc.Type] += acc.TotalEquity
+			case ByCurrency:
+				for _, bal := range acc.Balances {
+					rows[i][bal.Currency] += bal.TotalEquity
+				}
+			}
+			currencies[acc.Currency] = true
+		}
+		for g := range rows[i] {
+			groupSet[g] = true
+		}
+	}
+
+	for g := range groupSet {
+		series.Groups = append(series.Groups, g)
+	}
+	sort.Strings(series.Groups)
+	for i := range series.Times {
+		values := make([]*float64, len(series.Groups))
+		for j, g := range series.Groups {
+			if v, ok := rows[i][g]; ok {
+				values[j] = &v
+			}
+		}
+		series.Values = append(series.Values, values)
+	}
+	if by != ByCurrency && len(currencies) == 1 {
+		for c := range currencies {
+			series.Currency = c
+		}
+	}
+	return series, nil
+}
+
+// Totals returns the sum across groups at each time, or nil when grouping by currency
+// where groups cannot be added up
+func (s *Series) Totals() []float64 {
+	if s.By == ByCurrency {
+		return nil
+	}
+	totals := make([]float64, len(s.Times))
+	for i, values := range s.Values {
+		for _, v := range values {
+			if v != nil {
+				totals[i] += *v
+			}
+		}
+	}
+	return totals
+}
@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+)
+
+func TestAggregate(t *testing.T) {
+	day := func(d, hour int) time.Time { return time.Date(2026, 1, d, hour, 0, 0, 0, time.UTC) }
+	tfsa := func(v float64) Account {
+		return Account{Number: "1", Type: "TFSA", Currency: "CAD", TotalEquity: v, Balances: []CurrencyBalance{{Currency: "CAD", TotalEquity: v}}}
+	}
+	margin := func(v float64) Account {
+		return Account{Number: "2", Type: "Margin", Currency: "CAD", TotalEquity: v, Balances: []CurrencyBalance{{Currency: "CAD", TotalEquity: v - 100}, {Currency: "USD", TotalEquity: 100}}}
+	}
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name           string
+		snaps          []Snapshot
+		by, period     string
+		wantGroups     []string
+		wantTimes      []time.Time
+		wantValues     [][]*float64
+		wantIncomplete []bool
+	}{
+		{
+			name: "latest snapshot per day",
+			snaps: []Snapshot{
+				{TakenAt: day(5, 10), Accounts: []Account{tfsa(100), margin(500)}},
+				{TakenAt: day(5, 17), Accounts: []Account{tfsa(110), margin(510)}},
+				{TakenAt: day(6, 17), Accounts: []Account{tfsa(120), margin(520)}},
+			},
+			by: ByAccount, period: PeriodDay,
+			wantGroups: []string{"1 (TFSA)", "2 (Margin)"},
+			wantTimes:  []time.Time{day(5, 0), day(6, 0)},
+			wantValues: [][]*float64{{f(110), f(510)}, {f(120), f(520)}},
+		},
+		{
+			name: "unvalidated snapshots are skipped",
+			snaps: []Snapshot{
+				{TakenAt: day(5, 17), Accounts: []Account{tfsa(100)}},
+				{TakenAt: day(5, 18), Unvalidated: true, Accounts: []Account{tfsa(1)}},
+				{TakenAt: day(6, 17), Unvalidated: true, Accounts: []Account{tfsa(2)}},
+			},
+			by: ByType, period: PeriodDay,
+			wantGroups: []string{"TFSA"},
+			wantTimes:  []time.Time{day(5, 0)},
+			wantValues: [][]*float64{{f(100)}},
+		},
+		{
+			name: "missing balance keeps the last known value",
+			snaps: []Snapshot{
+				{TakenAt: day(5, 17), Accounts: []Account{tfsa(100), margin(500)}},
+				{TakenAt: day(6, 17), Accounts: []Account{tfsa(120)}, Missing: []Account{{Number: "2", Type: "Margin"}}},
+			},
+			by: ByType, period: PeriodDay,
+			wantGroups: []string{"Margin", "TFSA"},
+			wantTimes:  []time.Time{day(5, 0), day(6, 0)},
+			wantValues: [][]*float64{{f(500), f(100)}, {f(500), f(120)}},
+		},
+		{
+			name: "missing balance without a known value is incomplete",
+			snaps: []Snapshot{
+				{TakenAt: day(5, 17), Accounts: []Account{tfsa(100)}, Missing: []Account{{Number: "2", Type: "Margin"}}},
+				{TakenAt: day(6, 17), Accounts: []Account{tfsa(120), margin(520)}},
+			},
+			by: ByAccount, period: PeriodDay,
+			wantGroups:     []string{"1 (TFSA)", "2 (Margin)"},
+			wantTimes:      []time.Time{day(5, 0), day(6, 0)},
+			wantValues:     [][]*float64{{f(100), nil}, {f(120), f(520)}},
+			wantIncomplete: []bool{true, false},
+		},
+		{
+			name: "weeks start on Monday and group by currency",
+			snaps: []Snapshot{
+				// Sunday the 4th belongs to the week of Monday the 29th
+				{TakenAt: day(4, 17), Accounts: []Account{margin(500)}},
+				{TakenAt: day(7, 17), Accounts: []Account{tfsa(100), margin(600)}},
+			},
+			by: ByCurrency, period: PeriodWeek,
+			wantGroups: []string{"CAD", "USD"},
+			wantTimes:  []time.Time{time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC), day(5, 0)},
+			wantValues: [][]*float64{{f(400), f(100)}, {f(600), f(100)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Aggregate(tt.snaps, tt.by, tt.period, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Groups, tt.wantGroups) || !reflect.DeepEqual(got.Times, tt.wantTimes) ||
+				!reflect.DeepEqual(got.Values, tt.wantValues) || !reflect.DeepEqual(got.Incomplete, tt.wantIncomplete) {
+				t.Errorf("Aggregate() = groups %v times %v values %v incomplete %v, want %v %v %v %v",
+					got.Groups, got.Times, got.Values, got.Incomplete, tt.wantGroups, tt.wantTimes, tt.wantValues, tt.wantIncomplete)
+			}
+		})
+	}
+}
+
+func TestAggregateInvalid(t *testing.T) {
+	if _, err := Aggregate(nil, "broker", PeriodDay, time.UTC); err == nil {
+		t.Error("Aggregate() accepted an invalid grouping")
+	}
+	if _, err := Aggregate(nil, ByType, "year", time.UTC); err == nil {
+		t.Error("Aggregate() accepted an invalid period")
+	}
+}
+
+func TestTotals(t *testing.T) {
+	a, b := 100.0, 50.0
+	s := &Series{By: ByType, Times: []time.Time{{}, {}}, Values: [][]*float64{{&a, &b}, {&a, nil}}}
+	if got := s.Totals(); !reflect.DeepEqual(got, []float64{150, 100}) {
+		t.Errorf("Totals() = %v, want [150 100]", got)
+	}
+	s.By = ByCurrency
+	if got := s.Totals(); got != nil {
+		t.Errorf("Totals() by currency = %v, want nil", got)
+	}
+}
+
+func TestFromAccountsListsMissing(t *testing.T) {
+	accounts := []questrade.Account{
+		{Number: "1", Type: "TFSA", Balances: &questrade.AccountBalances{CombinedBalances: []questrade.PerCurrencyBalance{{Currency: "CAD", TotalEquity: 100}}}},
+		{Number: "2", Type: "Margin"},
+	}
+	snap := FromAccounts(accounts, SourceSync, time.Time{}, false)
+	if len(snap.Accounts) != 1 || !reflect.DeepEqual(snap.Missing, []Account{{Number: "2", Type: "Margin"}}) {
+		t.Errorf("FromAccounts() = %+v, want account 1 with 2 missing", snap)
+	}
+}
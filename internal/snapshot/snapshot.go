@@ -0,0 +1,107 @@
+// Package snapshot records Questrade account balances over time, stored as JSON lines
+// alongside the sync history, and aggregates them into time series for reporting.
+package snapshot
+
+import (
+	"sort"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/jsonl"
+	"github.com/brymastr/questrade-ynab/internal/questrade"
+)
+
+// Snapshot sources
+const (
+	SourceSync     = "sync"
+	SourceSnapshot = "snapshot"
+)
+
+// Snapshot is the balances of every Questrade account at one point in time
+type Snapshot struct {
+	TakenAt time.Time `json:"taken_at" yaml:"taken_at"`
+	Source  string    `json:"source" yaml:"source"`
+	// Intraday is set when balances were taken while markets were open
+	Intraday bool `json:"intraday,omitempty" yaml:"intraday,omitempty"`
+	// Unvalidated is set on sync snapshots whose balance checks found issues that were
+	// neither shown at a terminal nor ignored with --ignore-validation, or that were not
+	// checked because the run failed first. Such snapshots are left out of net worth
+	// reports, and disappeared accounts are detected against the last validated one, so
+	// unattended runs keep reporting them until someone has seen them.
+	Unvalidated bool      `json:"unvalidated,omitempty" yaml:"unvalidated,omitempty"`
+	Accounts    []Account `json:"accounts" yaml:"accounts"`
+	// Missing are the accounts Questrade listed without balances, by number and type
+	Missing []Account `json:"missing,omitempty" yaml:"missing,omitempty"`
+}
+
+// Account is one account's balances. TotalEquity is the combined balance in Currency;
+// Balances breaks it down by the currency the holdings are in.
+type Account struct {
+	Number      string            `json:"number" yaml:"number"`
+	Type        string            `json:"type" yaml:"type"`
+	Currency    string            `json:"currency" yaml:"currency"`
+	TotalEquity float64           `json:"total_equity" yaml:"total_equity"`
+	Balances    []CurrencyBalance `json:"balances,omitempty" yaml:"balances,omitempty"`
+}
+
+// CurrencyBalance is the part of an account held in one currency
+type CurrencyBalance struct {
+	Currency    string  `json:"currency" yaml:"currency"`
+	Cash        float64 `json:"cash" yaml:"cash"`
+	MarketValue float64 `json:"market_value" yaml:"market_value"`
+	TotalEquity float64 `json:"total_equity" yaml:"total_equity"`
+}
+
+// FromAccounts builds a snapshot from accounts fetched with their balances. Accounts
+// without balance information are listed in Missing instead.
+func FromAccounts(accounts []questrade.Account, source string, takenAt time.Time, intraday bool) Snapshot {
+	s := Snapshot{TakenAt: takenAt, Source: source, Intraday: intraday}
+	for _, acc := range accounts {
+		if acc.Balances == nil || len(acc.Balances.CombinedBalances) == 0 {
+			s.Missing = append(s.Missing, Account{Number: acc.Number, Type: acc.Type})
+			continue
+		}
+		combined := acc.Balances.CombinedBalances[0]
+		a := Account{
+			Number:      acc.Number,
+			Type:        acc.Type,
+			Currency:    combined.Currency,
+			TotalEquity: combined.TotalEquity,
+		}
+		for _, b := range acc.Balances.PerCurrencyBalances {
+			a.Balances = append(a.Balances, CurrencyBalance{
+				Currency:    b.Currency,
+				Cash:        b.Cash,
+				MarketValue: b.MarketValue,
+				TotalEquity: b.TotalEquity,
+			})
+		}
+		s.Accounts = append(s.Accounts, a)
+	}
+	return s
+}
+
+// Store is a snapshot file. Snapshots are appended one JSON object per line.
+type Store struct {
+	lines *jsonl.File[Snapshot]
+}
+
+// NewStore returns the store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{lines: jsonl.New[Snapshot](path)}
+}
+
+// Append adds a snapshot to the end of the file
+func (s *Store) Append(snap Snapshot) error {
+	return s.lines.Append(snap)
+}
+
+// List returns all snapshots, oldest first. A missing file yields none; unparseable
+// lines are skipped.
+func (s *Store) List() ([]Snapshot, error) {
+	snaps, err := s.lines.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].TakenAt.Before(snaps[j].TakenAt) })
+	return snaps, nil
+}
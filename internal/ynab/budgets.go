@@ -0,0 +1,165 @@
+package ynab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Budget ID aliases accepted by the YNAB API in place of a budget ID
+const (
+	// LastUsedBudgetID resolves to the most recently accessed budget
+	LastUsedBudgetID = "last-used"
+	// DefaultBudgetID resolves to the default budget, if default budget selection is enabled
+	DefaultBudgetID = "default"
+)
+
+// CurrencyFormat describes how a budget displays amounts
+type CurrencyFormat struct {
+	ISOCode          string `json:"iso_code"`
+	ExampleFormat    string `json:"example_format"`
+	DecimalDigits    int    `json:"decimal_digits"`
+	DecimalSeparator string `json:"decimal_separator"`
+	SymbolFirst      bool   `json:"symbol_first"`
+	GroupSeparator   string `json:"group_separator"`
+	CurrencySymbol   string `json:"currency_symbol"`
+	DisplaySymbol    bool   `json:"display_symbol"`
+}
+
+// DateFormat describes how a budget displays dates, e.g. "DD/MM/YYYY"
+type DateFormat struct {
+	Format string `json:"format"`
+}
+
+// BudgetSummary is a budget as listed by GetBudgets
+type BudgetSummary struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	LastModifiedOn string          `json:"last_modified_on"`
+	FirstMonth     string          `json:"first_month"`
+	LastMonth      string          `json:"last_month"`
+	DateFormat     *DateFormat     `json:"date_format,omitempty"`
+	CurrencyFormat *CurrencyFormat `json:"currency_format,omitempty"`
+}
+
+// Budget is a single budget as returned by GetBudget. Of the full export only the
+// summary fields and accounts are decoded.
+type Budget struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	LastModifiedOn string          `json:"last_modified_on"`
+	FirstMonth     string          `json:"first_month"`
+	LastMonth      string          `json:"last_month"`
+	DateFormat     *DateFormat     `json:"date_format,omitempty"`
+	CurrencyFormat *CurrencyFormat `json:"currency_format,omitempty"`
+	Accounts       []Account       `json:"accounts"`
+}
+
+// BudgetSettings holds the display settings of a budget
+type BudgetSettings struct {
+	DateFormat     DateFormat     `json:"date_format"`
+	CurrencyFormat CurrencyFormat `json:"currency_format"`
+}
+
+// BudgetsResponse is the payload of GET /budgets
+type BudgetsResponse struct {
+	Data struct {
+		Budgets       []BudgetSummary `json:"budgets"`
+		DefaultBudget *BudgetSummary  `json:"default_budget"`
+	} `json:"data"`
+}
+
+// GetBudgets retrieves all available budgets
+func (c *Client) GetBudgets() ([]BudgetSummary, error) {
+	return c.GetBudgetsContext(context.Background())
+}
+
+// GetBudgetsContext is like GetBudgets but bound to ctx
+func (c *Client) GetBudgetsContext(ctx context.Context) ([]BudgetSummary, error) {
+	budgets, _, err := c.ListBudgets(ctx)
+	return budgets, err
+}
+
+// ListBudgets retrieves all available budgets and the default budget, which is nil
+// unless default budget selection is enabled in YNAB
+func (c *Client) ListBudgets(ctx context.Context) ([]BudgetSummary, *BudgetSummary, error) {
+	var resp BudgetsResponse
+	if err := c.getJSON(ctx, &resp, nil, "budgets"); err != nil {
+		return nil, nil, err
+	}
+	return resp.Data.Budgets, resp.Data.DefaultBudget, nil
+}
+
+// ErrNoDefaultBudget is returned by FindBudget for DefaultBudgetID when default budget
+// selection is not enabled in YNAB
+var ErrNoDefaultBudget = errors.New("ynab: no default budget selected")
+
+// FindBudget returns the budget among those ListBudgets returned that id names: a budget
+// ID, DefaultBudgetID, or LastUsedBudgetID, which is taken to be the most recently
+// modified budget. An unknown ID is an error wrapping ErrNotFound.
+func FindBudget(budgets []BudgetSummary, defaultBudget *BudgetSummary, id string) (*BudgetSummary, error) {
+	switch id {
+	case DefaultBudgetID:
+		if defaultBudget == nil {
+			return nil, ErrNoDefaultBudget
+		}
+		return defaultBudget, nil
+	case LastUsedBudgetID:
+		var last *BudgetSummary
+		var lastModified time.Time
+		for i := range budgets {
+			t, err := time.Parse(time.RFC3339, budgets[i].LastModifiedOn)
+			if err != nil {
+				continue
+			}
+			if last == nil || t.After(lastModified) {
+				last, lastModified = &budgets[i], t
+			}
+		}
+		if last == nil {
+			return nil, fmt.Errorf("%w: no budgets in this YNAB account", ErrNotFound)
+		}
+		return last, nil
+	}
+	for i := range budgets {
+		if strings.EqualFold(budgets[i].ID, id) {
+			return &budgets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no budget with ID %q in this YNAB account", ErrNotFound, id)
+}
+
+// GetBudget retrieves a budget by ID or alias such as LastUsedBudgetID. It downloads the
+// full budget export; use ListBudgets and FindBudget to look a budget up.
+func (c *Client) GetBudget(ctx context.Context, budgetID string) (*Budget, error) {
+	var resp struct {
+		Data struct {
+			Budget Budget `json:"budget"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, nil, "budgets", budgetID); err != nil {
+		return nil, err
+	}
+	return &resp.Data.Budget, nil
+}
+
+// IsBudgetAlias reports whether id is one of the aliases YNAB resolves per request
+func IsBudgetAlias(id string) bool {
+	return id == LastUsedBudgetID || id == DefaultBudgetID
+}
+
+// GetBudgetSettings retrieves the date and currency format of the client's budget.
+// Budget ID aliases such as LastUsedBudgetID are resolved by YNAB.
+func (c *Client) GetBudgetSettings(ctx context.Context) (*BudgetSettings, error) {
+	var resp struct {
+		Data struct {
+			Settings BudgetSettings `json:"settings"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, nil, "budgets", c.budgetID, "settings"); err != nil {
+		return nil, err
+	}
+	return &resp.Data.Settings, nil
+}
@@ -0,0 +1,46 @@
+package ynab
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindBudget(t *testing.T) {
+	budgets := []BudgetSummary{
+		{ID: "aaaa-1111", Name: "Household", LastModifiedOn: "2026-01-05T17:00:00+00:00"},
+		{ID: "bbbb-2222", Name: "Business", LastModifiedOn: "2026-02-01T09:30:00.123+00:00"},
+		{ID: "cccc-3333", Name: "Archived", LastModifiedOn: ""},
+	}
+	household := &budgets[0]
+
+	tests := []struct {
+		name          string
+		budgets       []BudgetSummary
+		defaultBudget *BudgetSummary
+		id            string
+		want          string
+		wantErr       error
+	}{
+		{name: "by ID", budgets: budgets, id: "bbbb-2222", want: "bbbb-2222"},
+		{name: "ID case-insensitively", budgets: budgets, id: "AAAA-1111", want: "aaaa-1111"},
+		{name: "unknown ID", budgets: budgets, id: "dddd-4444", wantErr: ErrNotFound},
+		{name: "default", budgets: budgets, defaultBudget: household, id: DefaultBudgetID, want: "aaaa-1111"},
+		{name: "no default selected", budgets: budgets, id: DefaultBudgetID, wantErr: ErrNoDefaultBudget},
+		{name: "last used is the most recently modified", budgets: budgets, id: LastUsedBudgetID, want: "bbbb-2222"},
+		{name: "last used without budgets", id: LastUsedBudgetID, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindBudget(tt.budgets, tt.defaultBudget, tt.id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("FindBudget(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.ID != tt.want {
+				t.Errorf("FindBudget(%q) = %+v, %v, want %s", tt.id, got, err, tt.want)
+			}
+		})
+	}
+}
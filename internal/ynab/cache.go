@@ -0,0 +1,235 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brymastr/questrade-ynab/internal/atomicfile"
+)
+
+// Cache is a local copy of one budget's accounts, transactions, categories and payees.
+// Each collection remembers the server_knowledge of its last fetch so refreshing it only
+// transfers what changed, at the cost of a single request per collection.
+type Cache struct {
+	BudgetID  string          `json:"budget_id"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Settings  *BudgetSettings `json:"settings,omitempty"`
+
+	Accounts          []Account `json:"accounts"`
+	AccountsKnowledge int64     `json:"accounts_server_knowledge"`
+
+	Transactions          []Transaction `json:"transactions"`
+	TransactionsKnowledge int64         `json:"transactions_server_knowledge"`
+
+	CategoryGroups      []CategoryGroup `json:"category_groups"`
+	CategoriesKnowledge int64           `json:"categories_server_knowledge"`
+
+	Payees          []Payee `json:"payees"`
+	PayeesKnowledge int64   `json:"payees_server_knowledge"`
+}
+
+// CachePath returns the cache file for budgetID inside dir
+func CachePath(dir, budgetID string) string {
+	return filepath.Join(dir, budgetID+".json")
+}
+
+// LoadCache reads the cache at path. A missing file, or one written for a different
+// budget, yields an empty cache for budgetID.
+func LoadCache(path, budgetID string) (*Cache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Cache{BudgetID: budgetID}, nil
+		}
+		return nil, err
+	}
+	var c Cache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cache %s: %w", path, err)
+	}
+	if c.BudgetID != budgetID {
+		return &Cache{BudgetID: budgetID}, nil
+	}
+	return &c, nil
+}
+
+// Save atomically writes the cache to path
+func (c *Cache) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, data, 0600)
+}
+
+// SyncAccounts brings the cached accounts up to date
+func (c *Cache) SyncAccounts(ctx context.Context, client *Client) error {
+	changed, knowledge, err := client.GetAccountsDelta(ctx, c.AccountsKnowledge)
+	if err != nil {
+		return err
+	}
+	c.Accounts = mergeByID(c.Accounts, changed, func(a Account) (string, bool) { return a.ID, a.Deleted })
+	sort.Slice(c.Accounts, func(i, j int) bool { return c.Accounts[i].Name < c.Accounts[j].Name })
+	c.AccountsKnowledge = knowledge
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// SyncTransactions brings the cached transactions up to date
+func (c *Cache) SyncTransactions(ctx context.Context, client *Client) error {
+	changed, knowledge, err := client.GetTransactionsDelta(ctx, c.TransactionsKnowledge)
+	if err != nil {
+		return err
+	}
+	c.Transactions = mergeByID(c.Transactions, changed, func(t Transaction) (string, bool) { return t.ID, t.Deleted })
+	sort.SliceStable(c.Transactions, func(i, j int) bool { return c.Transactions[i].Date < c.Transactions[j].Date })
+	c.TransactionsKnowledge = knowledge
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// SyncCategories brings the cached category groups and their categories up to date
+func (c *Cache) SyncCategories(ctx context.Context, client *Client) error {
+	changed, knowledge, err := client.GetCategoriesDelta(ctx, c.CategoriesKnowledge)
+	if err != nil {
+		return err
+	}
+	// A delta group only lists its changed categories, so merge categories within groups
+	existing := make(map[string]CategoryGroup, len(c.CategoryGroups))
+	for _, g := range c.CategoryGroups {
+		existing[g.ID] = g
+	}
+	for i, g := range changed {
+		changed[i].Categories = mergeByID(existing[g.ID].Categories, g.Categories, func(cat Category) (string, bool) { return cat.ID, cat.Deleted })
+	}
+	c.CategoryGroups = mergeByID(c.CategoryGroups, changed, func(g CategoryGroup) (string, bool) { return g.ID, g.Deleted })
+	c.CategoriesKnowledge = knowledge
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// SyncPayees brings the cached payees up to date
+func (c *Cache) SyncPayees(ctx context.Context, client *Client) error {
+	changed, knowledge, err := client.GetPayeesDelta(ctx, c.PayeesKnowledge)
+	if err != nil {
+		return err
+	}
+	c.Payees = mergeByID(c.Payees, changed, func(p Payee) (string, bool) { return p.ID, p.Deleted })
+	sort.Slice(c.Payees, func(i, j int) bool { return c.Payees[i].Name < c.Payees[j].Name })
+	c.PayeesKnowledge = knowledge
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// SyncSettings fetches the budget's date and currency format. Settings have no delta
+// endpoint, so they are refetched in full.
+func (c *Cache) SyncSettings(ctx context.Context, client *Client) error {
+	settings, err := client.GetBudgetSettings(ctx)
+	if err != nil {
+		return err
+	}
+	c.Settings = settings
+	return nil
+}
+
+// CurrencyFormat returns the cached budget currency format, or nil if unknown
+func (c *Cache) CurrencyFormat() *CurrencyFormat {
+	if c.Settings == nil {
+		return nil
+	}
+	return &c.Settings.CurrencyFormat
+}
+
+// Sync brings every cached collection up to date
+func (c *Cache) Sync(ctx context.Context, client *Client) error {
+	for _, syncFn := range []func(context.Context, *Client) error{c.SyncSettings, c.SyncAccounts, c.SyncCategories, c.SyncPayees, c.SyncTransactions} {
+		if err := syncFn(ctx, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Categories returns all cached categories across groups
+func (c *Cache) Categories() []Category {
+	var cats []Category
+	for _, g := range c.CategoryGroups {
+		cats = append(cats, g.Categories...)
+	}
+	return cats
+}
+
+// FindCategory returns the cached category whose ID or name matches ref. Names are
+// matched case-insensitively and may be qualified by their group as "Group: Name".
+// Hidden and deleted categories are not matched by name.
+func (c *Cache) FindCategory(ref string) (*Category, error) {
+	ref = strings.TrimSpace(ref)
+	groupName, catName, qualified := strings.Cut(ref, ":")
+	var matches []*Category
+	for gi := range c.CategoryGroups {
+		g := &c.CategoryGroups[gi]
+		for ci := range g.Categories {
+			cat := &g.Categories[ci]
+			if cat.ID == ref {
+				return cat, nil
+			}
+			if cat.Deleted || cat.Hidden {
+				continue
+			}
+			if strings.EqualFold(cat.Name, ref) ||
+				(qualified && strings.EqualFold(strings.TrimSpace(g.Name), strings.TrimSpace(groupName)) && strings.EqualFold(cat.Name, strings.TrimSpace(catName))) {
+				matches = append(matches, cat)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("category %q not found", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("category %q is ambiguous; qualify it as \"Group: Name\" or use its ID", ref)
+	}
+}
+
+// mergeByID applies changed entities on top of existing ones, replacing entities with the
+// same ID and dropping those marked deleted. Order of existing entities is preserved.
+func mergeByID[T any](existing, changed []T, key func(T) (id string, deleted bool)) []T {
+	updates := make(map[string]T, len(changed))
+	var order []string
+	for _, e := range changed {
+		id, _ := key(e)
+		if _, seen := updates[id]; !seen {
+			order = append(order, id)
+		}
+		updates[id] = e
+	}
+	merged := make([]T, 0, len(existing)+len(changed))
+	for _, e := range existing {
+		id, _ := key(e)
+		if u, ok := updates[id]; ok {
+			e = u
+			delete(updates, id)
+		}
+		if _, deleted := key(e); !deleted {
+			merged = append(merged, e)
+		}
+	}
+	for _, id := range order {
+		if u, ok := updates[id]; ok {
+			if _, deleted := key(u); !deleted {
+				merged = append(merged, u)
+			}
+		}
+	}
+	return merged
+}
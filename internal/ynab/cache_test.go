@@ -0,0 +1,154 @@
+package ynab
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeByID(t *testing.T) {
+	key := func(p Payee) (string, bool) { return p.ID, p.Deleted }
+	existing := []Payee{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}, {ID: "c", Name: "C"}}
+
+	tests := []struct {
+		name    string
+		changed []Payee
+		want    []Payee
+	}{
+		{"no changes", nil, existing},
+		{
+			name:    "updated entity replaced in place",
+			changed: []Payee{{ID: "b", Name: "B2"}},
+			want:    []Payee{{ID: "a", Name: "A"}, {ID: "b", Name: "B2"}, {ID: "c", Name: "C"}},
+		},
+		{
+			name:    "deleted entity dropped",
+			changed: []Payee{{ID: "a", Deleted: true}},
+			want:    []Payee{{ID: "b", Name: "B"}, {ID: "c", Name: "C"}},
+		},
+		{
+			name:    "new entities appended in delta order",
+			changed: []Payee{{ID: "e", Name: "E"}, {ID: "d", Name: "D"}},
+			want:    []Payee{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}, {ID: "c", Name: "C"}, {ID: "e", Name: "E"}, {ID: "d", Name: "D"}},
+		},
+		{
+			name:    "new entity deleted in the same delta",
+			changed: []Payee{{ID: "d", Name: "D", Deleted: true}},
+			want:    existing,
+		},
+		{
+			name:    "last change to an ID wins",
+			changed: []Payee{{ID: "c", Name: "C2"}, {ID: "c", Name: "C3"}},
+			want:    []Payee{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}, {ID: "c", Name: "C3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeByID(existing, tt.changed, key)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeByID() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncCategories(t *testing.T) {
+	c, fake := newFakeYNAB(t, map[string]fakeResponse{"GET /v1/budgets/b/categories": {200, `{"data":{"server_knowledge":12,"category_groups":[
+		{"id":"g1","name":"Bills","categories":[
+			{"id":"rent","category_group_id":"g1","name":"Rent and Mortgage"},
+			{"id":"phone","category_group_id":"g1","name":"Phone","deleted":true},
+			{"id":"water","category_group_id":"g1","name":"Water"}]},
+		{"id":"g2","name":"Old","deleted":true,"categories":[]},
+		{"id":"g3","name":"Investments","categories":[{"id":"gains","category_group_id":"g3","name":"Gains"}]}]}}`}})
+	cache := &Cache{
+		BudgetID:            "b",
+		CategoriesKnowledge: 7,
+		CategoryGroups: []CategoryGroup{
+			{ID: "g1", Name: "Bills", Categories: []Category{
+				{ID: "rent", CategoryGroupID: "g1", Name: "Rent"},
+				{ID: "phone", CategoryGroupID: "g1", Name: "Phone"},
+				{ID: "power", CategoryGroupID: "g1", Name: "Power"},
+			}},
+			{ID: "g2", Name: "Old", Categories: []Category{{ID: "old", CategoryGroupID: "g2", Name: "Old"}}},
+		},
+	}
+
+	if err := cache.SyncCategories(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if fake.requests[0].query != "last_knowledge_of_server=7" {
+		t.Errorf("query = %q, want the cached server knowledge", fake.requests[0].query)
+	}
+	if cache.CategoriesKnowledge != 12 {
+		t.Errorf("CategoriesKnowledge = %d, want 12", cache.CategoriesKnowledge)
+	}
+	want := []CategoryGroup{
+		{ID: "g1", Name: "Bills", Categories: []Category{
+			{ID: "rent", CategoryGroupID: "g1", Name: "Rent and Mortgage"},
+			{ID: "power", CategoryGroupID: "g1", Name: "Power"},
+			{ID: "water", CategoryGroupID: "g1", Name: "Water"},
+		}},
+		{ID: "g3", Name: "Investments", Categories: []Category{{ID: "gains", CategoryGroupID: "g3", Name: "Gains"}}},
+	}
+	if !reflect.DeepEqual(cache.CategoryGroups, want) {
+		t.Errorf("CategoryGroups = %+v, want %+v", cache.CategoryGroups, want)
+	}
+}
+
+func TestSyncFullFetch(t *testing.T) {
+	c, fake := newFakeYNAB(t, map[string]fakeResponse{"GET /v1/budgets/b/payees": {200, `{"data":{"server_knowledge":3,"payees":[
+		{"id":"p2","name":"Zoo"},{"id":"p1","name":"Airline"},{"id":"p3","name":"Gone","deleted":true}]}}`}})
+	cache := &Cache{BudgetID: "b"}
+	if err := cache.SyncPayees(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if fake.requests[0].query != "" {
+		t.Errorf("query = %q, want a full fetch", fake.requests[0].query)
+	}
+	want := []Payee{{ID: "p1", Name: "Airline"}, {ID: "p2", Name: "Zoo"}}
+	if !reflect.DeepEqual(cache.Payees, want) || cache.PayeesKnowledge != 3 {
+		t.Errorf("Payees = %+v (knowledge %d), want %+v (knowledge 3)", cache.Payees, cache.PayeesKnowledge, want)
+	}
+}
+
+func TestSyncFailureKeepsCache(t *testing.T) {
+	c, _ := newFakeYNAB(t, nil)
+	cache := &Cache{BudgetID: "b", Payees: []Payee{{ID: "p1"}}, PayeesKnowledge: 5}
+	if err := cache.SyncPayees(context.Background(), c); err == nil {
+		t.Fatal("SyncPayees() succeeded against a failing server")
+	}
+	if len(cache.Payees) != 1 || cache.PayeesKnowledge != 5 {
+		t.Errorf("cache changed after a failed sync: %+v", cache)
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	dir := t.TempDir()
+	path := CachePath(dir, "b")
+	saved := &Cache{BudgetID: "b", Payees: []Payee{{ID: "p1", Name: "Airline"}}, PayeesKnowledge: 4}
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, budgetID string
+		want                 *Cache
+	}{
+		{"round trip", path, "b", saved},
+		{"other budget", path, "other", &Cache{BudgetID: "other"}},
+		{"missing file", filepath.Join(dir, "none.json"), "b", &Cache{BudgetID: "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadCache(tt.path, tt.budgetID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.UpdatedAt = tt.want.UpdatedAt
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadCache() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package ynab
+
+import (
+	"context"
+	"net/http"
+)
+
+// SetCategoryTarget changes the target amount of a category and returns the updated
+// category. YNAB only accepts this for categories that already have a target; the kind
+// of target is left as it is.
+func (c *Client) SetCategoryTarget(ctx context.Context, categoryID string, target Milliunits) (*Category, error) {
+	payload := map[string]interface{}{
+		"category": map[string]interface{}{"goal_target": target},
+	}
+	var resp struct {
+		Data struct {
+			Category Category `json:"category"`
+		} `json:"data"`
+	}
+	if err := c.sendJSON(ctx, http.MethodPatch, payload, http.StatusOK, &resp, "budgets", c.budgetID, "categories", categoryID); err != nil {
+		return nil, err
+	}
+	return &resp.Data.Category, nil
+}
@@ -1,58 +1,82 @@
 package ynab
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
 	"net/http"
 	"time"
 )
 
-// Transaction represents a YNAB transaction to be created
+// Transaction represents a YNAB transaction. Fields tagged omitempty are only populated
+// on transactions read back from YNAB.
 type Transaction struct {
-	AccountID string `json:"account_id"`
-	Date      string `json:"date"`
-	Amount    int64  `json:"amount"`
-	PayeeName string `json:"payee_name"`
-	Memo      string `json:"memo,omitempty"`
-	Cleared   string `json:"cleared,omitempty"`
-	Approved  bool   `json:"approved"`
+	ID         string     `json:"id,omitempty"`
+	AccountID  string     `json:"account_id"`
+	Date       string     `json:"date"`
+	Amount     Milliunits `json:"amount"`
+	PayeeID    string     `json:"payee_id,omitempty"`
+	PayeeName  string     `json:"payee_name"`
+	CategoryID string     `json:"category_id,omitempty"`
+	Memo       string     `json:"memo,omitempty"`
+	Cleared    string     `json:"cleared,omitempty"`
+	Approved   bool       `json:"approved"`
+	FlagColor  string     `json:"flag_color,omitempty"`
+	Deleted    bool       `json:"deleted,omitempty"`
+	// Subtransactions split the transaction across categories; their amounts add up to Amount
+	Subtransactions []SubTransaction `json:"subtransactions,omitempty"`
 }
 
+// SubTransaction is one line of a split transaction
+type SubTransaction struct {
+	Amount     Milliunits `json:"amount"`
+	PayeeName  string     `json:"payee_name,omitempty"`
+	CategoryID string     `json:"category_id,omitempty"`
+	Memo       string     `json:"memo,omitempty"`
+	Deleted    bool       `json:"deleted,omitempty"`
+}
+
+// FlagColors are the flag colors YNAB accepts on transactions
+var FlagColors = []string{"red", "orange", "yellow", "green", "blue", "purple"}
+
 type CreateTransactionRequest struct {
 	Transaction Transaction `json:"transaction"`
 }
 
-// CreateTransaction posts a single transaction to YNAB
-func (c *Client) CreateTransaction(tx Transaction) error {
-	url := fmt.Sprintf("%s/budgets/%s/transactions", baseURL, c.budgetID)
-	reqBody := CreateTransactionRequest{Transaction: tx}
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal transaction: %w", err)
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// CreateTransaction posts a single transaction to YNAB and returns it as created,
+// including its ID
+func (c *Client) CreateTransaction(tx Transaction) (*Transaction, error) {
+	return c.CreateTransactionContext(context.Background(), tx)
+}
+
+// CreateTransactionContext is like CreateTransaction but bound to ctx
+func (c *Client) CreateTransactionContext(ctx context.Context, tx Transaction) (*Transaction, error) {
+	var resp struct {
+		Data struct {
+			Transaction Transaction `json:"transaction"`
+		} `json:"data"`
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+	reqBody := CreateTransactionRequest{Transaction: tx}
+	if err := c.sendJSON(ctx, http.MethodPost, reqBody, http.StatusCreated, &resp, "budgets", c.budgetID, "transactions"); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	return &resp.Data.Transaction, nil
+}
+
+// DeleteTransaction deletes a transaction from the budget
+func (c *Client) DeleteTransaction(ctx context.Context, transactionID string) error {
+	return c.sendJSON(ctx, http.MethodDelete, nil, http.StatusOK, nil, "budgets", c.budgetID, "transactions", transactionID)
+}
+
+// GetTransaction retrieves a single transaction, including its subtransactions
+func (c *Client) GetTransaction(ctx context.Context, transactionID string) (*Transaction, error) {
+	var resp struct {
+		Data struct {
+			Transaction Transaction `json:"transaction"`
+		} `json:"data"`
 	}
-	if resp.StatusCode != http.StatusCreated {
-		var errResp ErrorResponse
-		_ = json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("YNAB API error %d: %s - %s", resp.StatusCode, errResp.Error.Name, errResp.Error.Detail)
+	if err := c.getJSON(ctx, &resp, nil, "budgets", c.budgetID, "transactions", transactionID); err != nil {
+		return nil, err
 	}
-	return nil
+	return &resp.Data.Transaction, nil
 }
 
 const baseURL = "https://api.ynab.com/v1"
@@ -61,30 +85,29 @@ type Client struct {
 	accessToken string
 	budgetID    string
 	httpClient  *http.Client
+	rateLimit   rateLimitTracker
 }
 
 type Account struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Balance int64  `json:"balance"`
-	Note    string `json:"note,omitempty"`
-	Closed  bool   `json:"closed"`
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Type             string     `json:"type"`
+	OnBudget         bool       `json:"on_budget"`
+	Balance          Milliunits `json:"balance"`
+	ClearedBalance   Milliunits `json:"cleared_balance"`
+	UnclearedBalance Milliunits `json:"uncleared_balance"`
+	Note             string     `json:"note,omitempty"`
+	Closed           bool       `json:"closed"`
+	Deleted          bool       `json:"deleted"`
 }
 
 type AccountsResponse struct {
 	Data struct {
-		Accounts []Account `json:"accounts"`
+		Accounts        []Account `json:"accounts"`
+		ServerKnowledge int64     `json:"server_knowledge"`
 	} `json:"data"`
 }
 
-type UpdateAccountRequest struct {
-	Account struct {
-		Cleared   int64 `json:"cleared"`
-		Uncleared int64 `json:"uncleared,omitempty"`
-	} `json:"account"`
-}
-
 type ErrorResponse struct {
 	Error struct {
 		ID     string `json:"id"`
@@ -101,122 +124,120 @@ func NewClient(accessToken, budgetID string) *Client {
 	}
 }
 
+// SetHTTPClient replaces the underlying HTTP client, e.g. to change the default 10s timeout.
+// Per-request deadlines are better expressed through the context passed to the *Context methods.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
 // GetAccounts retrieves all accounts in the specified budget
 func (c *Client) GetAccounts() ([]Account, error) {
-	url := fmt.Sprintf("%s/budgets/%s/accounts", baseURL, c.budgetID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		json.Unmarshal(body, &errResp)
-		return nil, fmt.Errorf("API returned status %d: %s - %s", resp.StatusCode, errResp.Error.Name, errResp.Error.Detail)
-	}
-
-	var accountsResp AccountsResponse
-	if err := json.Unmarshal(body, &accountsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse accounts response: %w", err)
-	}
-
-	return accountsResp.Data.Accounts, nil
+	return c.GetAccountsContext(context.Background())
 }
 
-// UpdateAccountBalance updates the cleared balance for an account
-// amount should be in milliunits (multiply by 1000 if in regular units)
-func (c *Client) UpdateAccountBalance(accountID string, amountMilliunits int64) error {
-	url := fmt.Sprintf("%s/budgets/%s/accounts/%s", baseURL, c.budgetID, accountID)
-
-	updateReq := UpdateAccountRequest{}
-	updateReq.Account.Cleared = amountMilliunits
-
-	body, err := json.Marshal(updateReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+// GetAccountsContext is like GetAccounts but bound to ctx
+func (c *Client) GetAccountsContext(ctx context.Context) ([]Account, error) {
+	accounts, _, err := c.GetAccountsDelta(ctx, 0)
+	return accounts, err
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+// GetAccount retrieves a single account in the budget
+func (c *Client) GetAccount(ctx context.Context, accountID string) (*Account, error) {
+	var resp struct {
+		Data struct {
+			Account Account `json:"account"`
+		} `json:"data"`
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("API returned status %d: %s - %s", resp.StatusCode, errResp.Error.Name, errResp.Error.Detail)
+	if err := c.getJSON(ctx, &resp, nil, "budgets", c.budgetID, "accounts", accountID); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return &resp.Data.Account, nil
 }
 
-// GetBudgets retrieves all available budgets
-func (c *Client) GetBudgets() ([]map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/budgets", baseURL)
+// BalanceChange describes an account balance adjustment made by SetAccountBalance
+type BalanceChange struct {
+	// ClearedBalance and UnclearedBalance are the account's balances before the adjustment
+	ClearedBalance   Milliunits
+	UnclearedBalance Milliunits
+	// Adjustment is the amount posted, 0 if the cleared balance already matched
+	Adjustment Milliunits
+	// TransactionID is the ID of the adjustment transaction, empty if none was posted
+	TransactionID string
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// SetAccountBalance brings an account's cleared balance to target by posting an
+// adjustment transaction for the difference. YNAB has no endpoint to set a balance
+// directly. Uncleared transactions are pending entries the target does not reflect yet,
+// so they are excluded from the comparison and stay pending; they are reported in the
+// result. tmpl supplies the date, payee, memo and cleared status (default "cleared") of
+// the adjustment.
+//
+// When tmpl has subtransactions the adjustment is split: every line but the last keeps
+// its amount and the last takes the rest, so the split stays balanced if the account
+// changed since the amounts were planned. Lines that end up zero are dropped, and a
+// single remaining line is posted as a plain transaction.
+func (c *Client) SetAccountBalance(ctx context.Context, accountID string, target Milliunits, tmpl Transaction) (BalanceChange, error) {
+	var change BalanceChange
+	account, err := c.GetAccount(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return change, err
 	}
+	change.ClearedBalance = account.ClearedBalance
+	change.UnclearedBalance = account.UnclearedBalance
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+	diff := target - account.ClearedBalance
+	if diff == 0 {
+		return change, nil
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	tx := tmpl
+	tx.AccountID = accountID
+	tx.Amount = diff
+	if len(tx.Subtransactions) > 0 {
+		splitAdjustment(&tx)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		json.Unmarshal(body, &errResp)
-		return nil, fmt.Errorf("API returned status %d: %s - %s", resp.StatusCode, errResp.Error.Name, errResp.Error.Detail)
+	if tx.Cleared == "" {
+		tx.Cleared = ClearedStatusCleared
 	}
-
-	var budgetsResp map[string]interface{}
-	if err := json.Unmarshal(body, &budgetsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse budgets response: %w", err)
+	created, err := c.CreateTransactionContext(ctx, tx)
+	if err != nil {
+		return change, err
 	}
+	change.Adjustment = diff
+	change.TransactionID = created.ID
+	return change, nil
+}
 
-	data := budgetsResp["data"].(map[string]interface{})
-	budgets := data["budgets"].([]interface{})
-	var result []map[string]interface{}
-	for _, b := range budgets {
-		result = append(result, b.(map[string]interface{}))
+// splitAdjustment balances tx.Subtransactions against tx.Amount as SetAccountBalance
+// describes
+func splitAdjustment(tx *Transaction) {
+	lines := append([]SubTransaction(nil), tx.Subtransactions...)
+	last := len(lines) - 1
+	rest := tx.Amount
+	for _, l := range lines[:last] {
+		rest -= l.Amount
+	}
+	lines[last].Amount = rest
+
+	var kept []SubTransaction
+	for _, l := range lines {
+		if l.Amount != 0 {
+			kept = append(kept, l)
+		}
+	}
+	tx.Subtransactions = nil
+	switch len(kept) {
+	case 0:
+	case 1:
+		tx.CategoryID = kept[0].CategoryID
+		if kept[0].PayeeName != "" {
+			tx.PayeeName = kept[0].PayeeName
+		}
+		if kept[0].Memo != "" {
+			tx.Memo = kept[0].Memo
+		}
+	default:
+		// A split's category is set per line
+		tx.CategoryID = ""
+		tx.Subtransactions = kept
 	}
-
-	return result, nil
 }
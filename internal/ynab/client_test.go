@@ -0,0 +1,164 @@
+package ynab
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitAdjustment(t *testing.T) {
+	contrib := SubTransaction{PayeeName: "Contribution", CategoryID: "rta", Memo: "Contribution"}
+	market := SubTransaction{CategoryID: "gains", Memo: "Market movement"}
+	with := func(l SubTransaction, amount Milliunits) SubTransaction {
+		l.Amount = amount
+		return l
+	}
+
+	tests := []struct {
+		name   string
+		amount Milliunits
+		lines  []SubTransaction
+		want   Transaction
+	}{
+		{
+			name:   "last line takes the remainder",
+			amount: 1500000,
+			lines:  []SubTransaction{with(contrib, 1000000), market},
+			want: Transaction{Amount: 1500000, PayeeName: "Stock Market", Memo: "Questrade sync", Subtransactions: []SubTransaction{
+				with(contrib, 1000000), with(market, 500000),
+			}},
+		},
+		{
+			name:   "loss larger than the contribution",
+			amount: -200000,
+			lines:  []SubTransaction{with(contrib, 1000000), market},
+			want: Transaction{Amount: -200000, PayeeName: "Stock Market", Memo: "Questrade sync", Subtransactions: []SubTransaction{
+				with(contrib, 1000000), with(market, -1200000),
+			}},
+		},
+		{
+			name:   "contribution only collapses to one line",
+			amount: 1000000,
+			lines:  []SubTransaction{with(contrib, 1000000), market},
+			want:   Transaction{Amount: 1000000, PayeeName: "Contribution", Memo: "Contribution", CategoryID: "rta"},
+		},
+		{
+			name:   "no contribution keeps the market line",
+			amount: 250000,
+			lines:  []SubTransaction{with(contrib, 0), market},
+			want:   Transaction{Amount: 250000, PayeeName: "Stock Market", Memo: "Market movement", CategoryID: "gains"},
+		},
+		{
+			name:   "nothing left",
+			amount: 0,
+			lines:  []SubTransaction{with(contrib, 0), market},
+			want:   Transaction{Amount: 0, PayeeName: "Stock Market", Memo: "Questrade sync", CategoryID: "preset"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := Transaction{Amount: tt.amount, PayeeName: "Stock Market", Memo: "Questrade sync", CategoryID: "preset", Subtransactions: tt.lines}
+			splitAdjustment(&tx)
+			if !reflect.DeepEqual(tx, tt.want) {
+				t.Errorf("splitAdjustment() = %+v, want %+v", tx, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitAdjustmentKeepsInput(t *testing.T) {
+	lines := []SubTransaction{{Amount: 100}, {Amount: 0}}
+	tx := Transaction{Amount: 300, Subtransactions: lines}
+	splitAdjustment(&tx)
+	if lines[1].Amount != 0 {
+		t.Errorf("splitAdjustment modified the caller's lines: %+v", lines)
+	}
+}
+
+func TestSetAccountBalance(t *testing.T) {
+	account := fakeResponse{200, `{"data":{"account":{"id":"acc","cleared_balance":1000000,"uncleared_balance":-20000}}}`}
+	created := fakeResponse{201, `{"data":{"transaction":{"id":"adj"}}}`}
+
+	tests := []struct {
+		name      string
+		target    Milliunits
+		tmpl      Transaction
+		responses map[string]fakeResponse
+		wantPost  map[string]interface{}
+		want      BalanceChange
+		wantErr   bool
+	}{
+		{
+			name:      "matching balance posts nothing",
+			target:    1000000,
+			responses: map[string]fakeResponse{"GET /v1/budgets/b/accounts/acc": account},
+			want:      BalanceChange{ClearedBalance: 1000000, UnclearedBalance: -20000},
+		},
+		{
+			name:      "difference to the cleared balance",
+			target:    900000,
+			tmpl:      Transaction{Date: "2026-01-05", PayeeName: "Investment Loss", Memo: "TFSA", Approved: true, FlagColor: "red", CategoryID: "losses"},
+			responses: map[string]fakeResponse{"GET /v1/budgets/b/accounts/acc": account, "POST /v1/budgets/b/transactions": created},
+			wantPost: map[string]interface{}{
+				"account_id": "acc", "date": "2026-01-05", "amount": -100000.0, "payee_name": "Investment Loss", "memo": "TFSA",
+				"cleared": "cleared", "approved": true, "flag_color": "red", "category_id": "losses",
+			},
+			want: BalanceChange{ClearedBalance: 1000000, UnclearedBalance: -20000, Adjustment: -100000, TransactionID: "adj"},
+		},
+		{
+			name:   "split with the market movement taking the rest",
+			target: 1600000,
+			tmpl: Transaction{Date: "2026-01-05", PayeeName: "Stock Market", Cleared: "reconciled", Subtransactions: []SubTransaction{
+				{Amount: 400000, PayeeName: "Contribution", CategoryID: "rta", Memo: "Contribution"},
+				{CategoryID: "gains", Memo: "Market movement"},
+			}},
+			responses: map[string]fakeResponse{"GET /v1/budgets/b/accounts/acc": account, "POST /v1/budgets/b/transactions": created},
+			wantPost: map[string]interface{}{
+				"account_id": "acc", "date": "2026-01-05", "amount": 600000.0, "payee_name": "Stock Market",
+				"cleared": "reconciled", "approved": false,
+				"subtransactions": []interface{}{
+					map[string]interface{}{"amount": 400000.0, "payee_name": "Contribution", "category_id": "rta", "memo": "Contribution"},
+					map[string]interface{}{"amount": 200000.0, "category_id": "gains", "memo": "Market movement"},
+				},
+			},
+			want: BalanceChange{ClearedBalance: 1000000, UnclearedBalance: -20000, Adjustment: 600000, TransactionID: "adj"},
+		},
+		{
+			name:   "failed post",
+			target: 1100000,
+			responses: map[string]fakeResponse{
+				"GET /v1/budgets/b/accounts/acc":  account,
+				"POST /v1/budgets/b/transactions": {400, `{"error":{"id":"400","name":"bad_request","detail":"invalid category"}}`},
+			},
+			wantPost: map[string]interface{}{"account_id": "acc", "date": "", "amount": 100000.0, "payee_name": "", "cleared": "cleared", "approved": false},
+			want:     BalanceChange{ClearedBalance: 1000000, UnclearedBalance: -20000},
+			wantErr:  true,
+		},
+		{
+			name:    "unknown account",
+			target:  1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newFakeYNAB(t, tt.responses)
+			got, err := c.SetAccountBalance(context.Background(), "acc", tt.target, tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetAccountBalance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SetAccountBalance() = %+v, want %+v", got, tt.want)
+			}
+			var post map[string]interface{}
+			for _, r := range fake.requests {
+				if r.method == "POST" {
+					post, _ = r.body["transaction"].(map[string]interface{})
+				}
+			}
+			if !reflect.DeepEqual(post, tt.wantPost) {
+				t.Errorf("POST transaction = %v, want %v", post, tt.wantPost)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package ynab
+
+import "context"
+
+// Delta requests: passing the server_knowledge returned by a previous call as
+// lastKnowledge makes YNAB return only entities changed since then, including deleted
+// ones (Deleted set). Pass 0 for a full fetch.
+
+// Category is a budget category
+type Category struct {
+	ID              string     `json:"id"`
+	CategoryGroupID string     `json:"category_group_id"`
+	Name            string     `json:"name"`
+	Hidden          bool       `json:"hidden"`
+	Budgeted        Milliunits `json:"budgeted"`
+	Activity        Milliunits `json:"activity"`
+	Balance         Milliunits `json:"balance"`
+	// GoalType is the kind of target set on the category, empty if none
+	GoalType   string     `json:"goal_type,omitempty"`
+	GoalTarget Milliunits `json:"goal_target"`
+	Deleted    bool       `json:"deleted"`
+}
+
+// CategoryGroup is a group of categories
+type CategoryGroup struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hidden     bool       `json:"hidden"`
+	Deleted    bool       `json:"deleted"`
+	Categories []Category `json:"categories"`
+}
+
+// Payee is a transaction payee
+type Payee struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	TransferAccountID string `json:"transfer_account_id,omitempty"`
+	Deleted           bool   `json:"deleted"`
+}
+
+// GetAccountsDelta returns accounts changed since lastKnowledge and the new server knowledge
+func (c *Client) GetAccountsDelta(ctx context.Context, lastKnowledge int64) ([]Account, int64, error) {
+	var resp AccountsResponse
+	if err := c.getJSON(ctx, &resp, knowledgeQuery(lastKnowledge), "budgets", c.budgetID, "accounts"); err != nil {
+		return nil, 0, err
+	}
+	return resp.Data.Accounts, resp.Data.ServerKnowledge, nil
+}
+
+// GetTransactionsDelta returns transactions changed since lastKnowledge and the new server knowledge
+func (c *Client) GetTransactionsDelta(ctx context.Context, lastKnowledge int64) ([]Transaction, int64, error) {
+	var resp struct {
+		Data struct {
+			Transactions    []Transaction `json:"transactions"`
+			ServerKnowledge int64         `json:"server_knowledge"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, knowledgeQuery(lastKnowledge), "budgets", c.budgetID, "transactions"); err != nil {
+		return nil, 0, err
+	}
+	return resp.Data.Transactions, resp.Data.ServerKnowledge, nil
+}
+
+// GetCategoriesDelta returns category groups changed since lastKnowledge and the new server knowledge
+func (c *Client) GetCategoriesDelta(ctx context.Context, lastKnowledge int64) ([]CategoryGroup, int64, error) {
+	var resp struct {
+		Data struct {
+			CategoryGroups  []CategoryGroup `json:"category_groups"`
+			ServerKnowledge int64           `json:"server_knowledge"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, knowledgeQuery(lastKnowledge), "budgets", c.budgetID, "categories"); err != nil {
+		return nil, 0, err
+	}
+	return resp.Data.CategoryGroups, resp.Data.ServerKnowledge, nil
+}
+
+// GetPayeesDelta returns payees changed since lastKnowledge and the new server knowledge
+func (c *Client) GetPayeesDelta(ctx context.Context, lastKnowledge int64) ([]Payee, int64, error) {
+	var resp struct {
+		Data struct {
+			Payees          []Payee `json:"payees"`
+			ServerKnowledge int64   `json:"server_knowledge"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, knowledgeQuery(lastKnowledge), "budgets", c.budgetID, "payees"); err != nil {
+		return nil, 0, err
+	}
+	return resp.Data.Payees, resp.Data.ServerKnowledge, nil
+}
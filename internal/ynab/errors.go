@@ -0,0 +1,57 @@
+package ynab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors classifying API failures. Test with errors.Is; use errors.As with
+// *APIError for the YNAB error id, name and detail.
+var (
+	ErrUnauthorized = errors.New("ynab: unauthorized")
+	ErrNotFound     = errors.New("ynab: not found")
+	ErrRateLimited  = errors.New("ynab: rate limited")
+)
+
+// APIError is returned for any non-successful YNAB API response
+type APIError struct {
+	StatusCode int
+	// ID, Name and Detail come from the YNAB error body, e.g. "404.2" "resource_not_found"
+	ID     string
+	Name   string
+	Detail string
+}
+
+func (e *APIError) Error() string {
+	if e.Name == "" && e.Detail == "" {
+		return fmt.Sprintf("YNAB API error %d", e.StatusCode)
+	}
+	return fmt.Sprintf("YNAB API error %d: %s - %s", e.StatusCode, e.Name, e.Detail)
+}
+
+// Is maps the error onto the package sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// newAPIError builds an APIError from a failed response and its already-read body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	var errResp ErrorResponse
+	_ = json.Unmarshal(body, &errResp)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		ID:         errResp.Error.ID,
+		Name:       errResp.Error.Name,
+		Detail:     errResp.Error.Detail,
+	}
+}
@@ -0,0 +1,113 @@
+package ynab
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Milliunits is a currency amount in YNAB's integer representation: 1000 milliunits
+// make one unit of currency. Keep amounts in Milliunits and convert at the edges so
+// sums and differences are exact.
+type Milliunits int64
+
+// FromUnits converts an amount in currency units (e.g. dollars) to milliunits,
+// rounding to the nearest milliunit rather than truncating.
+func FromUnits(units float64) Milliunits {
+	return Milliunits(math.Round(units * 1000))
+}
+
+// Units returns the amount in currency units
+func (m Milliunits) Units() float64 {
+	return float64(m) / 1000
+}
+
+// defaultCurrencyFormat is used when a budget's format is unknown
+var defaultCurrencyFormat = CurrencyFormat{
+	ISOCode:          "USD",
+	DecimalDigits:    2,
+	DecimalSeparator: ".",
+	SymbolFirst:      true,
+	GroupSeparator:   ",",
+	CurrencySymbol:   "$",
+	DisplaySymbol:    true,
+}
+
+// ForCurrency returns a format for amounts in the currency iso. base is returned as is
+// when it is for iso or iso is empty. Otherwise amounts keep base's digits and separators
+// (the default format's when base is nil) and are labelled with the ISO code instead of
+// a symbol that could be mistaken for the budget's, e.g. "CAD 1,234.56".
+func ForCurrency(iso string, base *CurrencyFormat) *CurrencyFormat {
+	if iso == "" || (base != nil && base.ISOCode == iso) {
+		return base
+	}
+	cf := defaultCurrencyFormat
+	if base != nil {
+		cf = *base
+	}
+	cf.ISOCode = iso
+	cf.CurrencySymbol = iso + " "
+	cf.SymbolFirst = true
+	cf.DisplaySymbol = true
+	return &cf
+}
+
+// Format renders the amount using the budget's currency format: symbol placement,
+// decimal digits and separators. A nil format renders like "$1,234.56".
+func (m Milliunits) Format(cf *CurrencyFormat) string {
+	if cf == nil {
+		cf = &defaultCurrencyFormat
+	}
+	digits := cf.DecimalDigits
+	if digits < 0 || digits > 3 {
+		digits = 2
+	}
+
+	// Round half away from zero to the format's precision, in integer arithmetic
+	scale := int64(math.Pow10(3 - digits))
+	v := int64(m)
+	negative := v < 0
+	if negative {
+		v = -v
+	}
+	v = (v + scale/2) / scale
+
+	pow := int64(math.Pow10(digits))
+	whole := groupDigits(strconv.FormatInt(v/pow, 10), cf.GroupSeparator)
+	number := whole
+	if digits > 0 {
+		frac := strconv.FormatInt(v%pow, 10)
+		number += cf.DecimalSeparator + strings.Repeat("0", digits-len(frac)) + frac
+	}
+
+	if cf.DisplaySymbol && cf.CurrencySymbol != "" {
+		if cf.SymbolFirst {
+			number = cf.CurrencySymbol + number
+		} else {
+			number = number + cf.CurrencySymbol
+		}
+	}
+	if negative && v != 0 {
+		number = "-" + number
+	}
+	return number
+}
+
+// groupDigits inserts sep between groups of three digits
+func groupDigits(s, sep string) string {
+	if sep == "" || len(s) <= 3 {
+		return s
+	}
+	var b strings.Builder
+	head := len(s) % 3
+	if head > 0 {
+		b.WriteString(s[:head])
+	}
+	for i := head; i < len(s); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(s[i : i+3])
+	}
+	return b.String()
+}
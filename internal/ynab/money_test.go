@@ -0,0 +1,85 @@
+package ynab
+
+import "testing"
+
+func TestFromUnits(t *testing.T) {
+	tests := []struct {
+		units float64
+		want  Milliunits
+	}{
+		{0, 0},
+		{1234.56, 1234560},
+		{-0.01, -10},
+		// 0.1+0.2 is 0.30000000000000004 in float64; rounding keeps it exact
+		{0.1 + 0.2, 300},
+		{1.0005, 1001},
+	}
+	for _, tt := range tests {
+		if got := FromUnits(tt.units); got != tt.want {
+			t.Errorf("FromUnits(%v) = %d, want %d", tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestMilliunitsFormat(t *testing.T) {
+	euro := &CurrencyFormat{ISOCode: "EUR", DecimalDigits: 2, DecimalSeparator: ",", GroupSeparator: ".", CurrencySymbol: "€", DisplaySymbol: true}
+	yen := &CurrencyFormat{ISOCode: "JPY", DecimalDigits: 0, GroupSeparator: ",", CurrencySymbol: "¥", SymbolFirst: true, DisplaySymbol: true}
+	dinar := &CurrencyFormat{ISOCode: "KWD", DecimalDigits: 3, DecimalSeparator: ".", GroupSeparator: ",", CurrencySymbol: "KD", SymbolFirst: true, DisplaySymbol: true}
+	hidden := &CurrencyFormat{ISOCode: "CAD", DecimalDigits: 2, DecimalSeparator: ".", GroupSeparator: " ", CurrencySymbol: "$", SymbolFirst: true}
+
+	tests := []struct {
+		name string
+		m    Milliunits
+		cf   *CurrencyFormat
+		want string
+	}{
+		{"nil format", 1234560, nil, "$1,234.56"},
+		{"zero", 0, nil, "$0.00"},
+		{"negative", -1234560, nil, "-$1,234.56"},
+		{"small", 50, nil, "$0.05"},
+		{"rounds half away from zero", 1005, nil, "$1.01"},
+		{"rounds negative half away from zero", -1005, nil, "-$1.01"},
+		{"negative rounding to zero has no sign", -4, nil, "$0.00"},
+		{"millions", 1234567890, nil, "$1,234,567.89"},
+		{"exact group boundary", 100000000, nil, "$100,000.00"},
+		{"symbol last and European separators", 1234560, euro, "1.234,56€"},
+		{"no decimals", 1234567, yen, "¥1,235"},
+		{"three decimals", 1234567, dinar, "KD1,234.567"},
+		{"symbol hidden", -1234560, hidden, "-1 234.56"},
+		{"invalid digits fall back to two", 1234, &CurrencyFormat{DecimalDigits: 7, DecimalSeparator: "."}, "1.23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Format(tt.cf); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForCurrency(t *testing.T) {
+	cad := &CurrencyFormat{ISOCode: "CAD", DecimalDigits: 2, DecimalSeparator: ".", GroupSeparator: ",", CurrencySymbol: "$", SymbolFirst: true, DisplaySymbol: true}
+	euro := &CurrencyFormat{ISOCode: "EUR", DecimalDigits: 2, DecimalSeparator: ",", GroupSeparator: ".", CurrencySymbol: "€", DisplaySymbol: true}
+	tests := []struct {
+		name string
+		iso  string
+		base *CurrencyFormat
+		want string
+	}{
+		{"budget currency", "CAD", cad, "$1,234.56"},
+		{"no currency", "", cad, "$1,234.56"},
+		{"other currency", "USD", cad, "USD 1,234.56"},
+		{"keeps separators", "CAD", euro, "CAD 1.234,56"},
+		{"no budget format", "CAD", nil, "CAD 1,234.56"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Milliunits(1234560).Format(ForCurrency(tt.iso, tt.base)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if cad.CurrencySymbol != "$" || euro.ISOCode != "EUR" {
+		t.Error("ForCurrency modified its base format")
+	}
+}
@@ -0,0 +1,105 @@
+package ynab
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetries is how many times a 429 response is retried before giving up
+	maxRetries  = 3
+	baseBackoff = 2 * time.Second
+)
+
+// RateLimit is the request budget YNAB reported in the most recent X-Rate-Limit header.
+// YNAB allows a fixed number of requests per access token per rolling hour.
+type RateLimit struct {
+	Used  int
+	Limit int
+	// Known is false until a response carrying the header has been received
+	Known bool
+}
+
+// Remaining returns how many requests are left in the current window, or -1 if unknown
+func (r RateLimit) Remaining() int {
+	if !r.Known {
+		return -1
+	}
+	if r.Used >= r.Limit {
+		return 0
+	}
+	return r.Limit - r.Used
+}
+
+type rateLimitTracker struct {
+	mu    sync.Mutex
+	limit RateLimit
+}
+
+func (t *rateLimitTracker) get() RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// update parses an X-Rate-Limit header of the form "36/200"
+func (t *rateLimitTracker) update(h http.Header) {
+	used, limit, ok := strings.Cut(h.Get("X-Rate-Limit"), "/")
+	if !ok {
+		return
+	}
+	u, err := strconv.Atoi(strings.TrimSpace(used))
+	if err != nil {
+		return
+	}
+	l, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit = RateLimit{Used: u, Limit: l, Known: true}
+}
+
+// RateLimit returns the request budget reported by the most recent response
+func (c *Client) RateLimit() RateLimit {
+	return c.rateLimit.get()
+}
+
+// do sends a request, tracking the rate limit header and backing off on 429 responses.
+// The final response is returned as-is for the caller to interpret.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := c.httpClient.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		c.rateLimit.update(resp.Header)
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == maxRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		t := time.NewTimer(baseBackoff << attempt)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ynab
+
+import (
+	"context"
+	"net/http"
+)
+
+// Transaction cleared states
+const (
+	ClearedStatusUncleared  = "uncleared"
+	ClearedStatusCleared    = "cleared"
+	ClearedStatusReconciled = "reconciled"
+)
+
+// ReconciliationPayee is the payee YNAB's own reconcile flow uses for balance adjustments
+const ReconciliationPayee = "Reconciliation Balance Adjustment"
+
+// TransactionUpdate is a partial transaction update for UpdateTransactions. Only the
+// fields set are changed.
+type TransactionUpdate struct {
+	ID      string `json:"id"`
+	Cleared string `json:"cleared,omitempty"`
+}
+
+// ReconcileResult describes what ReconcileAccount changed
+type ReconcileResult struct {
+	// ClearedBalance is the account's cleared balance before any reconciliation adjustment
+	ClearedBalance Milliunits
+	// Adjustment is the amount of the reconciliation adjustment posted, 0 if none was needed
+	Adjustment Milliunits
+	// AdjustmentID is the ID of the reconciliation adjustment, empty if none was posted
+	AdjustmentID string
+	// Reconciled is the number of cleared transactions marked reconciled
+	Reconciled int
+}
+
+// GetAccountTransactions retrieves all transactions in an account
+func (c *Client) GetAccountTransactions(ctx context.Context, accountID string) ([]Transaction, error) {
+	var resp struct {
+		Data struct {
+			Transactions []Transaction `json:"transactions"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, &resp, nil, "budgets", c.budgetID, "accounts", accountID, "transactions"); err != nil {
+		return nil, err
+	}
+	return resp.Data.Transactions, nil
+}
+
+// UpdateTransactions applies partial updates to several transactions in one request
+func (c *Client) UpdateTransactions(ctx context.Context, updates []TransactionUpdate) error {
+	payload := struct {
+		Transactions []TransactionUpdate `json:"transactions"`
+	}{updates}
+	return c.sendJSON(ctx, http.MethodPatch, payload, http.StatusOK, nil, "budgets", c.budgetID, "transactions")
+}
+
+// ReconcileAccount mirrors YNAB's reconcile flow: if the account's cleared balance differs
+// from target, a reconciled "Reconciliation Balance Adjustment" transaction dated date is
+// posted for the difference, then every cleared transaction is marked reconciled.
+// Uncleared transactions are left alone. It costs at most four requests.
+func (c *Client) ReconcileAccount(ctx context.Context, accountID string, target Milliunits, date string) (ReconcileResult, error) {
+	var result ReconcileResult
+	change, err := c.SetAccountBalance(ctx, accountID, target, Transaction{
+		Date:      date,
+		PayeeName: ReconciliationPayee,
+		Cleared:   ClearedStatusReconciled,
+		Approved:  true,
+	})
+	result.ClearedBalance = change.ClearedBalance
+	result.Adjustment = change.Adjustment
+	result.AdjustmentID = change.TransactionID
+	if err != nil {
+		return result, err
+	}
+
+	txs, err := c.GetAccountTransactions(ctx, accountID)
+	if err != nil {
+		return result, err
+	}
+	var updates []TransactionUpdate
+	for _, tx := range txs {
+		if tx.Cleared == ClearedStatusCleared && !tx.Deleted {
+			updates = append(updates, TransactionUpdate{ID: tx.ID, Cleared: ClearedStatusReconciled})
+		}
+	}
+	if len(updates) == 0 {
+		return result, nil
+	}
+	if err := c.UpdateTransactions(ctx, updates); err != nil {
+		return result, err
+	}
+	result.Reconciled = len(updates)
+	return result, nil
+}
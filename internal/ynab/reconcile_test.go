@@ -0,0 +1,183 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// fakeResponse is a canned reply of fakeYNAB
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// fakeRequest is a request fakeYNAB received
+type fakeRequest struct {
+	method, path, query string
+	body                map[string]interface{}
+}
+
+// fakeYNAB serves canned responses keyed by "METHOD /path" below /v1 and records the
+// requests it received. Unknown routes answer 404.
+type fakeYNAB struct {
+	t         *testing.T
+	responses map[string]fakeResponse
+	requests  []fakeRequest
+}
+
+// newFakeYNAB returns a client for budget "b" whose requests are served by fake
+func newFakeYNAB(t *testing.T, responses map[string]fakeResponse) (*Client, *fakeYNAB) {
+	fake := &fakeYNAB{t: t, responses: responses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fakeRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &req.body); err != nil {
+				t.Errorf("%s %s: invalid JSON body: %v", r.Method, r.URL.Path, err)
+			}
+		}
+		fake.requests = append(fake.requests, req)
+		resp, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			resp = fakeResponse{http.StatusNotFound, `{"error":{"id":"404.2","name":"resource_not_found","detail":"Resource not found"}}`}
+		}
+		w.WriteHeader(resp.status)
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	c := NewClient("token", "b")
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	return c, fake
+}
+
+// calls returns the method and path of each request received
+func (f *fakeYNAB) calls() []string {
+	var calls []string
+	for _, r := range f.requests {
+		calls = append(calls, r.method+" "+r.path)
+	}
+	return calls
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReconcileAccount(t *testing.T) {
+	account := fakeResponse{200, `{"data":{"account":{"id":"acc","cleared_balance":1000000,"uncleared_balance":-5000}}}`}
+	created := fakeResponse{201, `{"data":{"transaction":{"id":"adj"}}}`}
+	adjustment := map[string]interface{}{
+		"account_id": "acc", "date": "2026-01-05", "amount": 250000.0,
+		"payee_name": ReconciliationPayee, "cleared": "reconciled", "approved": true,
+	}
+	transactions := fakeResponse{200, `{"data":{"transactions":[
+		{"id":"t1","cleared":"cleared"},
+		{"id":"t2","cleared":"uncleared"},
+		{"id":"t3","cleared":"reconciled"},
+		{"id":"t4","cleared":"cleared","deleted":true},
+		{"id":"t5","cleared":"cleared"}
+	]}}`}
+
+	tests := []struct {
+		name      string
+		target    Milliunits
+		responses map[string]fakeResponse
+		wantCalls []string
+		wantPost  map[string]interface{}
+		wantPatch []interface{}
+		want      ReconcileResult
+		wantErr   bool
+	}{
+		{
+			name:   "adjusts then reconciles cleared transactions",
+			target: 1250000,
+			responses: map[string]fakeResponse{
+				"GET /v1/budgets/b/accounts/acc":              account,
+				"POST /v1/budgets/b/transactions":             created,
+				"GET /v1/budgets/b/accounts/acc/transactions": transactions,
+				"PATCH /v1/budgets/b/transactions":            {200, `{"data":{}}`},
+			},
+			wantCalls: []string{
+				"GET /v1/budgets/b/accounts/acc",
+				"POST /v1/budgets/b/transactions",
+				"GET /v1/budgets/b/accounts/acc/transactions",
+				"PATCH /v1/budgets/b/transactions",
+			},
+			wantPost: adjustment,
+			wantPatch: []interface{}{
+				map[string]interface{}{"id": "t1", "cleared": "reconciled"},
+				map[string]interface{}{"id": "t5", "cleared": "reconciled"},
+			},
+			want: ReconcileResult{ClearedBalance: 1000000, Adjustment: 250000, AdjustmentID: "adj", Reconciled: 2},
+		},
+		{
+			name:   "balance already matches and nothing is cleared",
+			target: 1000000,
+			responses: map[string]fakeResponse{
+				"GET /v1/budgets/b/accounts/acc":              account,
+				"GET /v1/budgets/b/accounts/acc/transactions": {200, `{"data":{"transactions":[{"id":"t3","cleared":"reconciled"}]}}`},
+			},
+			wantCalls: []string{
+				"GET /v1/budgets/b/accounts/acc",
+				"GET /v1/budgets/b/accounts/acc/transactions",
+			},
+			want: ReconcileResult{ClearedBalance: 1000000},
+		},
+		{
+			name:   "failed adjustment stops before marking anything",
+			target: 1250000,
+			responses: map[string]fakeResponse{
+				"GET /v1/budgets/b/accounts/acc":  account,
+				"POST /v1/budgets/b/transactions": {500, `{"error":{"id":"500","name":"internal_server_error","detail":"oops"}}`},
+			},
+			wantCalls: []string{
+				"GET /v1/budgets/b/accounts/acc",
+				"POST /v1/budgets/b/transactions",
+			},
+			wantPost: adjustment,
+			want:     ReconcileResult{ClearedBalance: 1000000},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newFakeYNAB(t, tt.responses)
+			got, err := c.ReconcileAccount(context.Background(), "acc", tt.target, "2026-01-05")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileAccount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileAccount() = %+v, want %+v", got, tt.want)
+			}
+			if calls := fake.calls(); !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("requests = %v, want %v", calls, tt.wantCalls)
+			}
+			for _, r := range fake.requests {
+				switch r.method {
+				case http.MethodPost:
+					if got := r.body["transaction"]; !reflect.DeepEqual(got, tt.wantPost) {
+						t.Errorf("POST transaction = %v, want %v", got, tt.wantPost)
+					}
+				case http.MethodPatch:
+					if got := r.body["transactions"]; !reflect.DeepEqual(got, tt.wantPatch) {
+						t.Errorf("PATCH transactions = %v, want %v", got, tt.wantPatch)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package ynab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// getJSON issues an authenticated GET for the given path segments below the API base
+// URL (e.g. "budgets", id, "accounts") and decodes a 200 response into v.
+// Failures are returned as *APIError.
+func (c *Client) getJSON(ctx context.Context, v interface{}, query url.Values, path ...string) error {
+	u, err := url.JoinPath(baseURL, path...)
+	if err != nil {
+		return fmt.Errorf("failed to build request URL: %w", err)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// sendJSON issues an authenticated request with payload, if non-nil, encoded as the JSON body, and
+// decodes the response into v (if non-nil) when it has status want.
+// Failures are returned as *APIError.
+func (c *Client) sendJSON(ctx context.Context, method string, payload interface{}, want int, v interface{}, path ...string) error {
+	u, err := url.JoinPath(baseURL, path...)
+	if err != nil {
+		return fmt.Errorf("failed to build request URL: %w", err)
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != want {
+		return newAPIError(resp, respBody)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// knowledgeQuery returns the query for a delta request, or nil for a full fetch
+func knowledgeQuery(lastKnowledge int64) url.Values {
+	if lastKnowledge <= 0 {
+		return nil
+	}
+	return url.Values{"last_knowledge_of_server": {fmt.Sprint(lastKnowledge)}}
+}